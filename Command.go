@@ -14,11 +14,19 @@ func runCommand(parsed string, commandData *commands.CommandData) {
 	// Download file
 	case fileDownloadCmd.FullCommand():
 		filename, id := commands.GetFileCommandData(*fileDownloadName, *fileDownloadID)
+		if len(*fileDownloadTar) > 0 {
+			commandData.DownloadTar(filename, id, *fileDownloadTar)
+			return
+		}
+
 		commandData.DownloadFile(&commands.DownloadData{
-			FileName:  filename,
-			FileID:    id,
-			Preview:   *viewPreview && !*viewNoPreview,
-			LocalPath: *fileDownloadPath,
+			FileName:        filename,
+			FileID:          id,
+			Preview:         *viewPreview && !*viewNoPreview,
+			LocalPath:       *fileDownloadPath,
+			Unpack:          *fileDownloadUnpack,
+			StripComponents: *fileDownloadStrip,
+			Parallelism:     *appParallelism,
 		})
 
 	// View file
@@ -39,6 +47,10 @@ func runCommand(parsed string, commandData *commands.CommandData) {
 			Preview:  false,
 		})
 
+	// Verify
+	case verifyCmd.FullCommand():
+		commandData.Verify(*verifyFileName, *verifyFileID, *appParallelism)
+
 	// Upload
 	case appUpload.FullCommand():
 		commandData.UploadItems(*fileUploadPaths, *appParallelism, &commands.UploadData{
@@ -49,7 +61,11 @@ func runCommand(parsed string, commandData *commands.CommandData) {
 			PublicName:      *fileUploadPublicName,
 			ReplaceFileID:   *fileUploadReplace,
 			SetClip:         *fileUploadSetClipboard,
+			QR:              commands.QROptions{Terminal: *fileUploadQR, File: *fileUploadQRFile},
 			NoArchiving:     *fileUploadNoArchiving,
+			ArchiveFormat:   *fileUploadArchiveFormat,
+			KeepPaths:       *fileUploadKeepPaths,
+			SplitSize:       *fileUploadSplit,
 			All:             *appAll,
 			ReplaceSameName: *fileUploadReplaceSameName,
 		})
@@ -86,11 +102,25 @@ func runCommand(parsed string, commandData *commands.CommandData) {
 
 	// Publish file
 	case filePublishCmd.FullCommand():
-		commands.PublishFile(commandData, *filePublishName, *filePublishID, *publishPublicName, *fileUploadSetClipboard)
+		commands.PublishFile(commandData, *filePublishName, *filePublishID, *publishPublicName, *fileUploadSetClipboard, commands.QROptions{Terminal: *fileUploadQR, File: *fileUploadQRFile})
 
 	// UnPublish file
 	case fileUnPublishCmd.FullCommand():
-		commands.UnPublishFile(commandData, *fileUnPublishName, *fileUnPublishID)
+		commands.UnPublishFile(commandData, *fileUnPublishName, *fileUnPublishID, commands.AuditFilter{
+			MaxAge:        *unpublishOlderThan,
+			SensitiveTags: *unpublishTags,
+		})
+
+	// List public files
+	case publicListCmd.FullCommand():
+		commandData.ListPublicFiles()
+
+	// Audit public files
+	case publicAuditCmd.FullCommand():
+		commandData.AuditPublicFiles(commands.AuditFilter{
+			MaxAge:        *publicAuditOlderThan,
+			SensitiveTags: *publicAuditTags,
+		})
 
 	// Edit file
 	case fileEditCmd.FullCommand():
@@ -100,31 +130,56 @@ func runCommand(parsed string, commandData *commands.CommandData) {
 	case fileMoveCmd.FullCommand():
 		commands.UpdateFile(commandData, *fileMoveFile, 0, "", *fileMoveNewNs, nil, nil, nil, nil, false, false)
 
+	// Copy file
+	case fileCopyCmd.FullCommand():
+		// Allow 'cp --all -t tag <namespace>'
+		if *appAll && len(*fileCopyNewNs) == 0 {
+			*fileCopyNewNs, *fileCopyFile = *fileCopyFile, ""
+		}
+		commandData.CopyFiles(*fileCopyFile, 0, *fileCopyNewNs, *appParallelism)
+
+	// -- Archive commands
+	// List archive members
+	case archiveListCmd.FullCommand():
+		commandData.ListArchive(*archiveListFileName, *archiveListFileID)
+
+	// Extract archive members
+	case archiveGetCmd.FullCommand():
+		commandData.ExtractArchiveMembers(*archiveGetFile, *archiveGetMembers, *archiveGetOutput, *archiveGetStrip)
+
 	// -- Attributes commands
 	// List Tags
 	case tagListCmd.FullCommand():
-		commandData.ListAttributes(libdm.TagAttribute)
+		commandData.ListAttributes(libdm.TagAttribute, *tagListCounts)
 
 	// Update tag
 	case tagUpdateCmd.FullCommand():
-		commands.UpdateAttribute(commandData, libdm.TagAttribute, *tagUpdateName, *tagUpdateNewName)
+		commands.UpdateAttribute(commandData, libdm.TagAttribute, *tagUpdateName, *tagUpdateNewName, *tagUpdateRegex)
 
 	// Delete Tag
 	case tagDeleteCmd.FullCommand():
 		commands.DeleteAttribute(commandData, libdm.TagAttribute, *tagDeleteName)
 
+	// Merge tag
+	case tagMergeCmd.FullCommand():
+		commands.MergeAttribute(commandData, libdm.TagAttribute, *tagMergeFrom, *tagMergeInto)
+
 	// List Groups
 	case groupListCmd.FullCommand():
-		commandData.ListAttributes(libdm.GroupAttribute)
+		commandData.ListAttributes(libdm.GroupAttribute, *groupListCounts)
 
 	// Update group
 	case groupUpdateCmd.FullCommand():
-		commands.UpdateAttribute(commandData, libdm.GroupAttribute, *groupUpdateName, *groupUpdateNewName)
+		commands.UpdateAttribute(commandData, libdm.GroupAttribute, *groupUpdateName, *groupUpdateNewName, *groupUpdateRegex)
 
 	// Delete Group
 	case groupDeleteCmd.FullCommand():
 		commands.DeleteAttribute(commandData, libdm.GroupAttribute, *groupDeleteName)
 
+	// Merge group
+	case groupMergeCmd.FullCommand():
+		commands.MergeAttribute(commandData, libdm.GroupAttribute, *groupMergeFrom, *groupMergeInto)
+
 	// -- Namespace commands
 	// Create namespace
 	case namespaceCreateCmd.FullCommand():
@@ -145,7 +200,15 @@ func runCommand(parsed string, commandData *commands.CommandData) {
 	// Download files in namespace
 	case namespaceDownloadCmd.FullCommand():
 		commandData.FileAttributes.Namespace = *namespaceDownloadNs
-		commandData.DownloadNamespace(*namespaceDownloadExcludeGroups, *namespaceDownloadExcludeTags, *namespaceDownloadExcludeFiles, *appParallelism, *namespaceDownloadOutputDir)
+		commandData.DownloadNamespace(*namespaceDownloadExcludeGroups, *namespaceDownloadExcludeTags, *namespaceDownloadExcludeFiles, *appParallelism, *namespaceDownloadOutputDir, *namespaceDownloadOrder)
+
+	// Upload directory into namespace
+	case namespaceUploadCmd.FullCommand():
+		commandData.UploadNamespace(*namespaceUploadDir, *namespaceUploadNs, *namespaceUploadMapGroups, *namespaceUploadMapTags, *namespaceUploadKeepPaths, *appParallelism)
+
+	// Clone namespace
+	case namespaceCloneCmd.FullCommand():
+		commandData.CloneNamespace(*namespaceCloneSrc, *namespaceCloneDst, *appParallelism)
 
 	// -- Ping command
 	case appPing.FullCommand():
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/DataManager-Go/DataManagerCLI/commands"
+	dmConfig "github.com/DataManager-Go/libdatamanager/config"
+)
+
+// EnVarAliasDepth env var tracking nested macro calls
+const EnVarAliasDepth = "ALIAS_DEPTH"
+
+// Max depth of nested aliases
+const maxAliasDepth = 10
+
+// Expand aliases defined in the cli config. Single commands are
+// expanded in place, macros are executed and the app exits afterwards
+func expandAliases(args []string) []string {
+	cliCfg, err := commands.LoadCLIConfig(preparseConfigFile(args))
+	if err != nil || len(cliCfg.Aliases) == 0 {
+		// Errors are reported while initializing the config
+		return args
+	}
+
+	depth, _ := strconv.Atoi(os.Getenv(getEnVar(EnVarAliasDepth)))
+
+	for ; depth < maxAliasDepth; depth++ {
+		i := commandArgIndex(args)
+		if i < 0 || isBuiltinCommand(args[i]) {
+			return args
+		}
+
+		alias, ok := cliCfg.Aliases[args[i]]
+		if !ok {
+			return args
+		}
+
+		globalArgs, aliasArgs := args[:i], args[i+1:]
+
+		// Run macros
+		if len(alias) > 1 {
+			runMacro(alias, globalArgs, aliasArgs, depth+1)
+			os.Exit(0)
+		}
+
+		expanded, err := expandAliasCommand(alias[0], aliasArgs, true)
+		if err != nil {
+			fmt.Printf("Error expanding alias '%s': %s\n", args[i], err)
+			os.Exit(1)
+		}
+
+		args = append(append([]string{}, globalArgs...), expanded...)
+	}
+
+	fmt.Println("Error: aliases are nested too deep")
+	os.Exit(1)
+	return nil
+}
+
+// Run all commands of a macro in sequence as subprocesses.
+// Stops and exits with its exitcode if a command fails
+func runMacro(macro commands.Alias, globalArgs, args []string, depth int) {
+	executable, err := os.Executable()
+	if err != nil {
+		fmt.Println("Error:", err)
+		os.Exit(1)
+	}
+
+	for _, command := range macro {
+		expanded, err := expandAliasCommand(command, args, false)
+		if err != nil {
+			fmt.Printf("Error expanding '%s': %s\n", command, err)
+			os.Exit(1)
+		}
+
+		cmd := exec.Command(executable, append(append([]string{}, globalArgs...), expanded...)...)
+		cmd.Stdin = os.Stdin
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		cmd.Env = append(os.Environ(), fmt.Sprintf("%s=%d", getEnVar(EnVarAliasDepth), depth))
+
+		if err := cmd.Run(); err != nil {
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				os.Exit(exitErr.ExitCode())
+			}
+
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// Split command into args and replace the placeholders $1..$n with the
+// corresponding arg and $@ with all args. If appendArgs is true and no
+// placeholder is used, the args are appended to the command
+func expandAliasCommand(command string, args []string, appendArgs bool) ([]string, error) {
+	parts, err := splitCommandLine(command)
+	if err != nil {
+		return nil, err
+	}
+
+	var expanded []string
+	var usesPlaceholder bool
+
+	for _, part := range parts {
+		if part == "$@" {
+			expanded = append(expanded, args...)
+			usesPlaceholder = true
+			continue
+		}
+
+		if len(part) > 1 && part[0] == '$' {
+			if n, err := strconv.Atoi(part[1:]); err == nil && n > 0 {
+				if n > len(args) {
+					return nil, fmt.Errorf("missing argument $%d", n)
+				}
+
+				expanded = append(expanded, args[n-1])
+				usesPlaceholder = true
+				continue
+			}
+		}
+
+		expanded = append(expanded, part)
+	}
+
+	if appendArgs && !usesPlaceholder {
+		expanded = append(expanded, args...)
+	}
+
+	return expanded, nil
+}
+
+// Split a command line into args. Supports
+// single and double quotes and backslash escapes
+func splitCommandLine(s string) ([]string, error) {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	var inArg, escaped bool
+
+	for _, c := range s {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inArg = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t' || c == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape")
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// Determine the config file before the args are parsed
+func preparseConfigFile(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		switch {
+		case (arg == "-c" || arg == "--config") && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(arg, "--config="):
+			return strings.TrimPrefix(arg, "--config=")
+		}
+	}
+
+	if file := os.Getenv(getEnVar(EnVarConfigFile)); len(file) > 0 {
+		return file
+	}
+
+	return dmConfig.GetDefaultConfigFile()
+}
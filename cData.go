@@ -16,21 +16,23 @@ import (
 func buildCData(parsed string, appTrimName int) *commands.CommandData {
 	// Command data
 	commandData := commands.CommandData{
-		Command: parsed,
-		Config:  config,
-		Details: uint8(*appDetails),
+		Command:   parsed,
+		Config:    config,
+		CLIConfig: cliConfig,
+		Details:   uint8(*appDetails),
 		FileAttributes: libdm.FileAttributes{
 			Namespace: *appNamespace,
 			Groups:    *appGroups,
 			Tags:      *appTags,
 		},
-		Namespace:   *appNamespace,
-		All:         *appAll,
-		NoRedaction: *appNoRedaction,
-		OutputJSON:  *appOutputJSON,
-		Yes:         *appYes,
-		Force:       *appForce,
-		NameLen:     appTrimName,
+		Namespace:     *appNamespace,
+		All:           *appAll,
+		AllNamespaces: *appAllNamespaces,
+		NoRedaction:   *appNoRedaction,
+		OutputJSON:    *appOutputJSON,
+		Yes:           *appYes,
+		Force:         *appForce,
+		NameLen:       appTrimName,
 
 		Encryption: *appFileEncryption,
 
@@ -40,8 +42,22 @@ func buildCData(parsed string, appTrimName int) *commands.CommandData {
 		Quiet:               *appQuiet,
 		VerifyFile:          *appVerify,
 		UnmodifiedNamespace: unmodifiedNS,
-		Compression:         *appDisableCompression,
+		Compression:         *appCompression,
+		CompressionLevel:    *appCompressionLevel,
 		Extract:             *appDecompress,
+		NoPreserve:          *fileDownloadNoPreserve || *namespaceDownloadNoPreserve,
+		NoHooks:             *appNoHooks,
+		FailFast:            *appFailFast,
+	}
+
+	// --compressed is a shorthand for gzip
+	if *appDisableCompression && len(commandData.Compression) == 0 {
+		commandData.Compression = commands.CompressionGzip
+	}
+
+	if err := commands.CheckCompressionLevel(commandData.Compression, commandData.CompressionLevel); err != nil {
+		fmt.Println("Invalid compression level:", err)
+		return nil
 	}
 
 	// Init cdata
@@ -49,6 +65,26 @@ func buildCData(parsed string, appTrimName int) *commands.CommandData {
 		return nil
 	}
 
+	// Init bandwidth limits
+	if err := commandData.InitRateLimits(*appLimitRate, *appLimitUpload, *appLimitDownload); err != nil {
+		fmt.Println("Invalid bandwidth limit:", err)
+		return nil
+	}
+
+	// Init ignore patterns of uploaded directories
+	exclude := append(*fileUploadExclude, *namespaceUploadExclude...)
+	include := append(*fileUploadInclude, *namespaceUploadInclude...)
+	if err := commandData.InitFileFilter(exclude, include); err != nil {
+		fmt.Println("Invalid ignore pattern:", err)
+		return nil
+	}
+
+	// Init retry policy
+	if err := commandData.InitRetryPolicy(*appRetries); err != nil {
+		fmt.Println("Invalid retry policy:", err)
+		return nil
+	}
+
 	// Initialize encryption sources
 	return initInputKey(commandData)
 }
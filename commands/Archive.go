@@ -0,0 +1,773 @@
+package commands
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+	"github.com/fatih/color"
+	"github.com/sbani/go-humanizer/units"
+	clitable "gopkg.in/benweidig/cli-table.v2"
+)
+
+// Archive formats
+const (
+	ArchiveTar   = "tar"
+	ArchiveTarGz = "tar.gz"
+	ArchiveZip   = "zip"
+)
+
+// ArchiveFormats all formats folders can be archived in
+var ArchiveFormats = []string{ArchiveTar, ArchiveTarGz, ArchiveZip}
+
+var (
+	// ErrUnknownArchive if the format of an archive can't be detected
+	ErrUnknownArchive = errors.New("unknown archive format")
+
+	// ErrIllegalPath if an archive member would be written outside of the destination
+	ErrIllegalPath = errors.New("illegal path")
+
+	// ErrDirNotEmpty if an archive would be unpacked into a non empty directory
+	ErrDirNotEmpty = errors.New("directory not empty")
+
+	// ErrFileExists if an archive member would overwrite an existing file
+	ErrFileExists = errors.New("file already exists")
+)
+
+var zipMagic = []byte{'P', 'K', 0x03, 0x04}
+
+// Offset and value of the magic of ustar/gnu tar headers
+const tarMagicOffset = 257
+
+var tarMagic = []byte("ustar")
+
+// Returns the file suffix of an archive format
+func archiveSuffix(format string) string {
+	if len(format) == 0 {
+		format = ArchiveTar
+	}
+
+	return "." + format
+}
+
+// Returns the mimetype of an archive format
+func archiveMimetype(format string) string {
+	switch format {
+	case ArchiveTarGz:
+		return "application/gzip"
+	case ArchiveZip:
+		return "application/zip"
+	}
+
+	return "application/x-tar"
+}
+
+// Detect the archive format by its header
+func detectArchive(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return ArchiveZip
+	case len(header) >= tarMagicOffset+len(tarMagic) && bytes.Equal(header[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return ArchiveTar
+	}
+
+	return ""
+}
+
+// archiveReader reads an archive and closes its decompressor
+type archiveReader struct {
+	io.Reader
+	io.Closer
+}
+
+// Open an archive stream. Compressed archives get decompressed.
+// Returns the detected format and a reader of the archive
+func openArchive(r io.Reader) (string, io.ReadCloser, error) {
+	br := bufio.NewReaderSize(r, 1024)
+	var closer io.Closer = ioutil.NopCloser(nil)
+
+	header, _ := br.Peek(magicLen)
+	if algorithm := detectCompression(header); len(algorithm) > 0 {
+		dr, err := newDecompressor(br, algorithm)
+		if err != nil {
+			return "", nil, err
+		}
+
+		br = bufio.NewReaderSize(dr, 1024)
+		closer = dr
+	}
+
+	header, _ = br.Peek(tarMagicOffset + len(tarMagic))
+	format := detectArchive(header)
+	if len(format) == 0 {
+		closer.Close()
+		return "", nil, ErrUnknownArchive
+	}
+
+	return format, archiveReader{br, closer}, nil
+}
+
+// archiveWriter adds files to an archive
+type archiveWriter interface {
+	add(name, file string, info os.FileInfo) error
+	Close() error
+}
+
+// Write the folder dir as archive into w. Members are named
+// relative to the parent of dir so they get unpacked into a folder
+func writeArchive(w io.Writer, dir, format string, filter *fileFilter) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	base := filepath.Dir(dir)
+
+	var aw archiveWriter
+	switch format {
+	case ArchiveZip:
+		aw = &zipWriter{zw: zip.NewWriter(w)}
+	case ArchiveTarGz:
+		gw, err := newCompressor(w, CompressionGzip, 0)
+		if err != nil {
+			return err
+		}
+		aw = &tarWriter{tw: tar.NewWriter(gw), compressor: gw}
+	default:
+		aw = &tarWriter{tw: tar.NewWriter(w)}
+	}
+
+	err = filter.walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(base, file)
+		if err != nil {
+			return err
+		}
+
+		return aw.add(filepath.ToSlash(name), file, info)
+	})
+
+	if cerr := aw.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// tarWriter writes (compressed) tar archives
+type tarWriter struct {
+	tw         *tar.Writer
+	compressor io.WriteCloser
+}
+
+func (w *tarWriter) add(name, file string, info os.FileInfo) error {
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(file); err != nil {
+			return err
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	}
+
+	if err = w.tw.WriteHeader(header); err != nil {
+		return err
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	return writeFileTo(w.tw, file)
+}
+
+func (w *tarWriter) Close() error {
+	err := w.tw.Close()
+	if w.compressor != nil {
+		if cerr := w.compressor.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
+
+// zipWriter writes zip archives. The zip.Writer uses data
+// descriptors so the archive can be written as a stream
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipWriter) add(name, file string, info os.FileInfo) error {
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+
+	header.Name = name
+	if info.IsDir() {
+		header.Name += "/"
+	} else if info.Mode().IsRegular() {
+		header.Method = zip.Deflate
+	}
+
+	fw, err := w.zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		// Symlinks store their target as content
+		link, err := os.Readlink(file)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(fw, link)
+		return err
+	case info.Mode().IsRegular():
+		return writeFileTo(fw, file)
+	}
+
+	return nil
+}
+
+func (w *zipWriter) Close() error {
+	return w.zw.Close()
+}
+
+// Copy the content of file into w
+func writeFileTo(w io.Writer, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// archiveMember a file inside an archive
+type archiveMember struct {
+	Name     string      `json:"name"`
+	Size     int64       `json:"size"`
+	Mode     os.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"mtime"`
+	Linkname string      `json:"link,omitempty"`
+}
+
+// Called for each member of an archive with a reader of its content
+type archiveWalkFunc func(member *archiveMember, r io.Reader) error
+
+// Walk through all members of a tar or zip archive read from r
+func walkArchive(r io.Reader, fn archiveWalkFunc) error {
+	format, ar, err := openArchive(r)
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+
+	switch format {
+	case ArchiveTar:
+		err = walkTar(ar, fn)
+	case ArchiveZip:
+		err = walkZip(ar, fn)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// Consume padding after the end of the archive
+	_, err = io.Copy(ioutil.Discard, ar)
+	return err
+}
+
+func walkTar(r io.Reader, fn archiveWalkFunc) error {
+	tr := tar.NewReader(r)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		member := &archiveMember{
+			Name:     header.Name,
+			Size:     header.Size,
+			Mode:     header.FileInfo().Mode(),
+			ModTime:  header.ModTime,
+			Linkname: header.Linkname,
+		}
+
+		if err = fn(member, tr); err != nil {
+			return err
+		}
+	}
+}
+
+// Zip archives have their index at the end, so
+// they have to be buffered in a tempfile
+func walkZip(r io.Reader, fn archiveWalkFunc) error {
+	tmpFile, err := ioutil.TempFile("", "dm-archive-*.zip")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+	defer tmpFile.Close()
+
+	size, err := io.Copy(tmpFile, r)
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(tmpFile, size)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range zr.File {
+		if err = walkZipFile(file, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func walkZipFile(file *zip.File, fn archiveWalkFunc) error {
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	member := &archiveMember{
+		Name:    file.Name,
+		Size:    int64(file.UncompressedSize64),
+		Mode:    file.Mode(),
+		ModTime: file.Modified,
+	}
+
+	// Symlinks store their target as content
+	if member.Mode&os.ModeSymlink != 0 {
+		b, err := ioutil.ReadAll(io.LimitReader(rc, 4096))
+		if err != nil {
+			return err
+		}
+		member.Linkname = string(b)
+	}
+
+	return fn(member, rc)
+}
+
+// unpacker extracts archive members into a directory
+type unpacker struct {
+	dir       string
+	strip     int
+	overwrite bool // Replace existing files
+
+	// Extracted directories. Their modes and mtimes are
+	// set after their content was written
+	dirs []unpackedDir
+}
+
+type unpackedDir struct {
+	path  string
+	mode  os.FileMode
+	mtime time.Time
+}
+
+// Unpack a tar, tar.gz or zip archive read from r into dir. The first
+// strip path components of each member are removed
+func unpackArchive(r io.Reader, dir string, strip int) error {
+	return newUnpacker(dir, strip).unpack(r)
+}
+
+func newUnpacker(dir string, strip int) *unpacker {
+	return &unpacker{
+		dir:       dir,
+		strip:     strip,
+		overwrite: true,
+	}
+}
+
+func (u *unpacker) unpack(r io.Reader) error {
+	if err := os.MkdirAll(u.dir, 0750); err != nil {
+		return err
+	}
+
+	if err := walkArchive(r, u.extract); err != nil {
+		return err
+	}
+
+	return u.finish()
+}
+
+// Set the modes and mtimes of the extracted directories
+func (u *unpacker) finish() error {
+	// Children first
+	for i := len(u.dirs) - 1; i >= 0; i-- {
+		dir := u.dirs[i]
+
+		// Don't follow directories replaced by symlinks
+		if info, err := os.Lstat(dir.path); err != nil || !info.IsDir() {
+			continue
+		}
+
+		if err := os.Chmod(dir.path, dir.mode); err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(dir.path, dir.mtime, dir.mtime); err != nil {
+			return err
+		}
+	}
+
+	u.dirs = nil
+	return nil
+}
+
+// Extract a single member
+func (u *unpacker) extract(member *archiveMember, r io.Reader) error {
+	name := stripComponents(member.Name, u.strip)
+	if len(name) == 0 {
+		return nil
+	}
+
+	target, err := u.resolve(name)
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return err
+	}
+
+	mode := member.Mode
+
+	// Directories get merged, files only replaced if allowed
+	if !u.overwrite && !mode.IsDir() {
+		if _, err := os.Lstat(target); err == nil {
+			return fmt.Errorf("%w: %s", ErrFileExists, target)
+		}
+	}
+
+	switch {
+	case mode.IsDir():
+		// MkdirAll would follow a symlink extracted before
+		if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("%w: %s is a symlink", ErrIllegalPath, name)
+		}
+
+		u.dirs = append(u.dirs, unpackedDir{
+			path:  target,
+			mode:  mode.Perm(),
+			mtime: member.ModTime,
+		})
+
+		return os.MkdirAll(target, 0750)
+	case mode&os.ModeSymlink != 0:
+		// Links must not point outside of the destination
+		linkTarget := member.Linkname
+		if !filepath.IsAbs(linkTarget) {
+			linkTarget = filepath.Join(filepath.Dir(target), linkTarget)
+		}
+		if !u.isInside(linkTarget) {
+			return fmt.Errorf("%w: %s links to %s", ErrIllegalPath, name, member.Linkname)
+		}
+
+		os.Remove(target)
+		return os.Symlink(member.Linkname, target)
+	case mode.IsRegular():
+		if err = writeMember(target, r); err != nil {
+			return err
+		}
+	default:
+		// Skip devices, pipes etc.
+		return nil
+	}
+
+	if err = os.Chmod(target, mode.Perm()); err != nil {
+		return err
+	}
+
+	return os.Chtimes(target, member.ModTime, member.ModTime)
+}
+
+func writeMember(target string, r io.Reader) error {
+	// Don't write through existing symlinks
+	os.Remove(target)
+
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Returns the path to write name to. Fails if it would be outside of dir
+func (u *unpacker) resolve(name string) (string, error) {
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("%w: %s", ErrIllegalPath, name)
+		}
+	}
+
+	target := filepath.Join(u.dir, filepath.FromSlash(name))
+	if !u.isInside(target) {
+		return "", fmt.Errorf("%w: %s", ErrIllegalPath, name)
+	}
+
+	// Writing through symlinks extracted before could
+	// escape dir, even if the path itself is inside
+	rel, err := filepath.Rel(u.dir, target)
+	if err != nil {
+		return "", err
+	}
+
+	parent := u.dir
+	for _, part := range strings.Split(rel, string(filepath.Separator)) {
+		parent = filepath.Join(parent, part)
+
+		info, err := os.Lstat(parent)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if info.Mode()&os.ModeSymlink != 0 && parent != target {
+			return "", fmt.Errorf("%w: %s is inside a symlink", ErrIllegalPath, name)
+		}
+	}
+
+	return target, nil
+}
+
+func (u *unpacker) isInside(target string) bool {
+	rel, err := filepath.Rel(u.dir, target)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Returns true if dir doesn't exist or has no entries
+func isEmptyDir(dir string) bool {
+	f, err := os.Open(dir)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	defer f.Close()
+
+	_, err = f.Readdirnames(1)
+	return err == io.EOF
+}
+
+// Returns true if name equals a pattern, is inside a directory
+// given as pattern or matches a glob pattern
+func matchMember(name string, patterns []string) bool {
+	name = stripComponents(name, 0)
+
+	for _, pattern := range patterns {
+		pattern = stripComponents(pattern, 0)
+
+		if name == pattern || strings.HasPrefix(name, pattern+"/") {
+			return true
+		}
+
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Remove the first n components of a slash separated path
+func stripComponents(name string, n int) string {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		if len(part) > 0 && part != "." {
+			parts = append(parts, part)
+		}
+	}
+
+	if len(parts) <= n {
+		return ""
+	}
+
+	return strings.Join(parts[n:], "/")
+}
+
+// Stream the decrypted and extracted content of resp into read. Verifies
+// the checksum and the indexed digest after everything was read
+func (cData *CommandData) readArchive(resp *libdm.FileDownloadResponse, cancel chan bool, bar *Bar, read func(r io.Reader) error) error {
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		err := read(pr)
+		// Stop the download if reading failed
+		pr.CloseWithError(err)
+		done <- err
+	}()
+
+	digest, extracted, err := saveResponse(resp, pw, cData.downloadProxy(bar), true, cancel)
+	pw.CloseWithError(err)
+
+	if rerr := <-done; rerr != nil {
+		return rerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if !resp.VerifyChecksum() {
+		return libdm.ErrChecksumNotMatch
+	}
+
+	return cData.verifyDigest(resp, digest, extracted)
+}
+
+// ListArchive prints the members of a stored archive
+func (cData *CommandData) ListArchive(name string, id uint) {
+	name, id = GetFileCommandData(name, id)
+
+	resp, err := (&DownloadData{FileName: name, FileID: id}).doRequest(cData, false)
+	if err != nil {
+		printResponseError(err, "requesting file")
+		os.Exit(1)
+	}
+
+	var members []archiveMember
+	err = cData.readArchive(resp, nil, nil, func(r io.Reader) error {
+		return walkArchive(r, func(member *archiveMember, _ io.Reader) error {
+			members = append(members, *member)
+			return nil
+		})
+	})
+
+	if err != nil {
+		cData.printDownloadError(resp, err, nil)
+		os.Exit(1)
+	}
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(members))
+		return
+	}
+
+	if cData.Quiet {
+		for _, member := range members {
+			fmt.Println(member.Name)
+		}
+		return
+	}
+
+	headingColor := color.New(color.FgHiGreen, color.Underline, color.Bold)
+
+	table := clitable.New()
+	table.ColSeparator = " "
+	table.Padding = 4
+
+	table.AddRow([]interface{}{
+		headingColor.Sprint("Mode"), headingColor.Sprint("Size"), headingColor.Sprint("Modified"), headingColor.Sprint("Path"),
+	}...)
+
+	for _, member := range members {
+		name := member.Name
+		if len(member.Linkname) > 0 {
+			name += " -> " + member.Linkname
+		}
+
+		table.AddRow([]interface{}{member.Mode, units.BinarySuffix(float64(member.Size)), member.ModTime.Format("2006-01-02 15:04"), name}...)
+	}
+
+	fmt.Println(table)
+}
+
+// ExtractArchiveMembers extracts the members of a stored archive matching
+// patterns into outDir. Patterns can be paths, directories or globs
+func (cData *CommandData) ExtractArchiveMembers(file string, patterns []string, outDir string, strip int) {
+	name, id := GetFileCommandData(file, 0)
+
+	resp, err := (&DownloadData{FileName: name, FileID: id}).doRequest(cData, false)
+	if err != nil {
+		printResponseError(err, "requesting file")
+		os.Exit(1)
+	}
+
+	var extracted int
+	u := newUnpacker(gaw.ResolveFullPath(outDir), strip)
+	u.overwrite = cData.Force
+
+	err = cData.readArchive(resp, nil, nil, func(r io.Reader) error {
+		return walkArchive(r, func(member *archiveMember, content io.Reader) error {
+			if !matchMember(member.Name, patterns) {
+				return nil
+			}
+
+			if err := u.extract(member, content); err != nil {
+				return err
+			}
+
+			extracted++
+			if !cData.Quiet {
+				fmt.Println(member.Name)
+			}
+			return nil
+		})
+	})
+
+	if err == nil {
+		err = u.finish()
+	}
+
+	if errors.Is(err, ErrFileExists) {
+		fmt.Printf("%s. Use -f to overwrite it\n", err)
+		os.Exit(1)
+	}
+
+	if err != nil {
+		cData.printDownloadError(resp, err, nil)
+		os.Exit(1)
+	}
+
+	if extracted == 0 {
+		printError("extracting members", "no member matches")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testMember struct {
+	name, link, content string
+	dir                 bool
+}
+
+func buildTar(t *testing.T, members []testMember) *bytes.Buffer {
+	var buff bytes.Buffer
+	tw := tar.NewWriter(&buff)
+
+	for _, member := range members {
+		hdr := &tar.Header{Name: member.name, Mode: 0644, Typeflag: tar.TypeReg, Size: int64(len(member.content))}
+		switch {
+		case member.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		case len(member.link) > 0:
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, member.link
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(member.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return &buff
+}
+
+func TestUnpackArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		members []testMember
+		illegal bool
+	}{
+		{"plain", []testMember{{name: "d", dir: true}, {name: "d/x", content: "x"}, {name: "d/l", link: "x"}}, false},
+		{"dotdot", []testMember{{name: "../x", content: "x"}}, true},
+		{"link outside", []testMember{{name: "l", link: "../"}}, true},
+		{"nested links", []testMember{{name: "a", link: "."}, {name: "a/b", link: ".."}, {name: "a/b/x", content: "x"}}, true},
+		{"file in link", []testMember{{name: "a", link: "."}, {name: "a/x", content: "x"}}, true},
+		{"dir over link", []testMember{{name: "a", link: "."}, {name: "a", dir: true}}, true},
+	}
+
+	for _, test := range tests {
+		root, err := ioutil.TempDir("", "dmunpack")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dir := filepath.Join(root, "dst")
+
+		err = unpackArchive(buildTar(t, test.members), dir, 0)
+		if test.illegal != errors.Is(err, ErrIllegalPath) {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+		}
+
+		if _, err := os.Lstat(filepath.Join(root, "x")); err == nil {
+			t.Errorf("%s: file written outside of the destination", test.name)
+		}
+
+		os.RemoveAll(root)
+	}
+}
+
+func TestUnpackNoOverwrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dmunpack")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err = ioutil.WriteFile(filepath.Join(dir, "x"), []byte("old"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newUnpacker(dir, 0)
+	u.overwrite = false
+
+	members := []testMember{{name: "d", dir: true}, {name: "x", content: "new"}}
+	if err = u.unpack(buildTar(t, members)); !errors.Is(err, ErrFileExists) {
+		t.Errorf("expected ErrFileExists, got %v", err)
+	}
+
+	if content, _ := ioutil.ReadFile(filepath.Join(dir, "x")); string(content) != "old" {
+		t.Error("existing file was overwritten")
+	}
+}
@@ -2,58 +2,414 @@ package commands
 
 import (
 	"fmt"
+	"regexp"
+	"sort"
 
 	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
 	"github.com/fatih/color"
+	"github.com/sbani/go-humanizer/units"
+	clitable "gopkg.in/benweidig/cli-table.v2"
 )
 
-// UpdateAttribute update an attribute
-func UpdateAttribute(cData *CommandData, attribute libdm.Attribute, name, newName string) {
-	_, err := cData.LibDM.UpdateAttribute(attribute, cData.FileAttributes.Namespace, name, newName)
+// UpdateAttribute update an attribute. If regex is true, name is used as
+// regular expression and newName as replacement for all matching attributes
+func UpdateAttribute(cData *CommandData, attribute libdm.Attribute, name, newName string, regex bool) {
+	if len(newName) == 0 {
+		fmtError("Missing the new name")
+		return
+	}
+
+	namespaces, err := cData.attributeNamespaces()
+	if err != nil {
+		printResponseError(err, "listing namespaces")
+		return
+	}
+
+	// Rename a single attribute
+	if !regex {
+		var updated int
+		for _, namespace := range namespaces {
+			attributes, err := cData.getAttributes(attribute, namespace)
+			if err != nil {
+				printResponseError(err, "listing attributes")
+				return
+			}
+
+			// Only update attributes which exist in the namespace
+			exists := attributeInSlice(name, attributes)
+			if !exists && cData.AllNamespaces {
+				continue
+			}
+
+			// Merge into newName if it already exists
+			if exists && name != newName && attributeInSlice(newName, attributes) {
+				if _, err := cData.mergeAttribute(attribute, namespace, name, newName); err != nil {
+					printResponseError(err, "merging attribute")
+					return
+				}
+			} else if _, err := cData.LibDM.UpdateAttribute(attribute, namespace, name, newName); err != nil {
+				printResponseError(err, "updating attribute")
+				return
+			}
+
+			updated++
+		}
+
+		if updated == 0 {
+			fmt.Printf("No %s '%s' found\n", attribute, name)
+			return
+		}
+
+		fmt.Printf("The attribute has been %s\n", color.HiGreenString("successfully updated"))
+		return
+	}
+
+	re, err := regexp.Compile(name)
 	if err != nil {
-		printResponseError(err, "updating attribute")
+		printError("parsing regex", err.Error())
 		return
 	}
 
-	fmt.Printf("The attribute has been %s\n", color.HiGreenString("successfully updated"))
+	var renamed int
+	for _, namespace := range namespaces {
+		attributes, err := cData.getAttributes(attribute, namespace)
+		if err != nil {
+			printResponseError(err, "listing attributes")
+			return
+		}
+
+		for _, attr := range attributes {
+			if !re.MatchString(string(attr)) {
+				continue
+			}
+
+			target := re.ReplaceAllString(string(attr), newName)
+			if target == string(attr) {
+				continue
+			}
+
+			// Merge into target if it already exists
+			if attributeInSlice(target, attributes) {
+				if _, err := cData.mergeAttribute(attribute, namespace, string(attr), target); err != nil {
+					printResponseError(err, "merging attribute")
+					return
+				}
+			} else {
+				if _, err := cData.LibDM.UpdateAttribute(attribute, namespace, string(attr), target); err != nil {
+					printResponseError(err, "updating attribute")
+					return
+				}
+			}
+
+			if !cData.Quiet {
+				fmt.Printf("%s: %s -> %s\n", namespace, attr, target)
+			}
+			renamed++
+		}
+	}
+
+	fmt.Printf("Renamed %d %ss %s\n", renamed, attribute, color.HiGreenString("successfully"))
 }
 
 // DeleteAttribute delete an attribute
 func DeleteAttribute(cData *CommandData, attribute libdm.Attribute, name string) {
-	_, err := cData.LibDM.DeleteAttribute(attribute, cData.FileAttributes.Namespace, name)
+	namespaces, err := cData.attributeNamespaces()
 	if err != nil {
-		printResponseError(err, "deleting attribute")
+		printResponseError(err, "listing namespaces")
 		return
 	}
 
+	for _, namespace := range namespaces {
+		// Only delete attributes which exist in the namespace
+		if cData.AllNamespaces {
+			attributes, err := cData.getAttributes(attribute, namespace)
+			if err != nil || !attributeInSlice(name, attributes) {
+				continue
+			}
+		}
+
+		if _, err := cData.LibDM.DeleteAttribute(attribute, namespace, name); err != nil {
+			printResponseError(err, "deleting attribute")
+			return
+		}
+	}
+
 	fmt.Printf("The attribute has been %s\n", color.HiGreenString("successfully deleted"))
 }
 
-// ListAttributes lists attributes in a namespace
-func (cData *CommandData) ListAttributes(attribute libdm.Attribute) {
-	var attributes []libdm.Attribute
-	var err error
+// MergeAttribute assigns 'into' to all files having 'from' and deletes 'from'
+func MergeAttribute(cData *CommandData, attribute libdm.Attribute, from, into string) {
+	if from == into {
+		fmtError("Can't merge an attribute into itself")
+		return
+	}
 
-	switch attribute {
-	case libdm.GroupAttribute:
-		attributes, err = cData.LibDM.GetGroups(cData.FileAttributes.Namespace)
-	case libdm.TagAttribute:
-		attributes, err = cData.LibDM.GetTags(cData.FileAttributes.Namespace)
-	default:
+	namespaces, err := cData.attributeNamespaces()
+	if err != nil {
+		printResponseError(err, "listing namespaces")
+		return
+	}
+
+	var updated, merged int
+	for _, namespace := range namespaces {
+		// Skip namespaces which don't have the attribute
+		if cData.AllNamespaces {
+			attributes, err := cData.getAttributes(attribute, namespace)
+			if err != nil {
+				printResponseError(err, "listing attributes")
+				return
+			}
+
+			if !attributeInSlice(from, attributes) {
+				continue
+			}
+		}
+		merged++
+
+		n, err := cData.mergeAttribute(attribute, namespace, from, into)
+		if err != nil {
+			printResponseError(err, "merging attribute")
+			return
+		}
+
+		updated += n
+	}
+
+	if merged == 0 {
+		fmt.Printf("No %s '%s' found\n", attribute, from)
 		return
 	}
 
+	fmt.Printf("Merged '%s' into '%s' (%d files updated) %s\n", from, into, updated, color.HiGreenString("successfully"))
+}
+
+// ListAttributes lists attributes in a namespace
+func (cData *CommandData) ListAttributes(attribute libdm.Attribute, counts bool) {
+	namespaces, err := cData.attributeNamespaces()
 	if err != nil {
-		printError("listing attribute", err.Error())
+		printResponseError(err, "listing namespaces")
+		return
+	}
+
+	if counts {
+		cData.listAttributeCounts(attribute, namespaces)
+		return
+	}
+
+	var found bool
+	for _, namespace := range namespaces {
+		attributes, err := cData.getAttributes(attribute, namespace)
+		if err != nil {
+			printError("listing attribute", err.Error())
+			return
+		}
+
+		if len(attributes) == 0 {
+			continue
+		}
+		found = true
+
+		if cData.AllNamespaces {
+			fmt.Println(color.New(color.Bold, color.FgHiYellow).Sprint(namespace))
+		}
+
+		for i := range attributes {
+			fmt.Println(attributes[i])
+		}
+	}
+
+	if !found {
+		fmt.Println("No attributes found")
+	}
+}
+
+// attributeCount usage of an attribute
+type attributeCount struct {
+	Namespace string `json:"ns"`
+	Name      string `json:"name"`
+	Files     int    `json:"files"`
+	Size      int64  `json:"size"`
+}
+
+// List attributes with the amount and size of files using them
+func (cData *CommandData) listAttributeCounts(attribute libdm.Attribute, namespaces []string) {
+	var counts []attributeCount
+
+	for _, namespace := range namespaces {
+		attributes, err := cData.getAttributes(attribute, namespace)
+		if err != nil {
+			printError("listing attribute", err.Error())
+			return
+		}
+
+		resp, err := cData.listFiles("", 0, false, libdm.FileAttributes{
+			Namespace: namespace,
+		}, 2)
+		if err != nil {
+			printResponseError(err, "listing files")
+			return
+		}
+
+		// Count files per attribute
+		countMap := make(map[string]*attributeCount)
+		for i := range attributes {
+			countMap[string(attributes[i])] = &attributeCount{
+				Namespace: namespace,
+				Name:      string(attributes[i]),
+			}
+		}
+
+		for i := range resp.Files {
+			for _, attr := range fileAttributeSlice(&resp.Files[i], attribute) {
+				count, ok := countMap[attr]
+				if !ok {
+					count = &attributeCount{Namespace: namespace, Name: attr}
+					countMap[attr] = count
+				}
+
+				count.Files++
+				count.Size += resp.Files[i].Size
+			}
+		}
+
+		var nsCounts []attributeCount
+		for _, count := range countMap {
+			nsCounts = append(nsCounts, *count)
+		}
+
+		sort.Slice(nsCounts, func(i, j int) bool {
+			return nsCounts[i].Name < nsCounts[j].Name
+		})
+
+		counts = append(counts, nsCounts...)
+	}
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(counts))
 		return
 	}
 
-	if len(attributes) == 0 {
+	if len(counts) == 0 {
 		fmt.Println("No attributes found")
 		return
 	}
 
+	headingColor := color.New(color.FgHiGreen, color.Underline, color.Bold)
+
+	table := clitable.New()
+	table.ColSeparator = " "
+	table.Padding = 4
+
+	header := []interface{}{headingColor.Sprint("Name"), headingColor.Sprint("Files"), headingColor.Sprint("Size")}
+	if cData.AllNamespaces {
+		header = append(header, headingColor.Sprint("Namespace"))
+	}
+
+	if !cData.Quiet {
+		table.AddRow(header...)
+	}
+
+	for _, count := range counts {
+		row := []interface{}{count.Name, count.Files, units.BinarySuffix(float64(count.Size))}
+		if cData.AllNamespaces {
+			row = append(row, count.Namespace)
+		}
+
+		table.AddRow(row...)
+	}
+
+	fmt.Println(table)
+}
+
+// Assign 'into' to all files in namespace having 'from', then
+// delete 'from'. Returns the amount of updated files
+func (cData *CommandData) mergeAttribute(attribute libdm.Attribute, namespace, from, into string) (int, error) {
+	resp, err := cData.listFiles("", 0, false, libdm.FileAttributes{
+		Namespace: namespace,
+	}, 2)
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int
+	for i := range resp.Files {
+		file := &resp.Files[i]
+		attributes := fileAttributeSlice(file, attribute)
+		if !gaw.IsInStringArray(from, attributes) {
+			continue
+		}
+
+		changes := libdm.FileChanges{}
+		switch attribute {
+		case libdm.TagAttribute:
+			changes.RemoveTags = []string{from}
+			if !gaw.IsInStringArray(into, attributes) {
+				changes.AddTags = []string{into}
+			}
+		case libdm.GroupAttribute:
+			changes.RemoveGroups = []string{from}
+			if !gaw.IsInStringArray(into, attributes) {
+				changes.AddGroups = []string{into}
+			}
+		}
+
+		if _, err := cData.updateFile("", file.ID, namespace, false, changes); err != nil {
+			return updated, err
+		}
+		updated++
+	}
+
+	// Remove the merged attribute
+	if _, err = cData.LibDM.DeleteAttribute(attribute, namespace, from); err != nil {
+		printWarning("deleting attribute", err.Error())
+	}
+
+	return updated, nil
+}
+
+// Returns the namespaces an attribute command should be applied to
+func (cData *CommandData) attributeNamespaces() ([]string, error) {
+	if !cData.AllNamespaces {
+		return []string{cData.FileAttributes.Namespace}, nil
+	}
+
+	resp, err := cData.LibDM.GetNamespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(resp.Slice)
+	return resp.Slice, nil
+}
+
+// Get all attributes of a given type in namespace
+func (cData *CommandData) getAttributes(attribute libdm.Attribute, namespace string) ([]libdm.Attribute, error) {
+	switch attribute {
+	case libdm.GroupAttribute:
+		return cData.LibDM.GetGroups(namespace)
+	case libdm.TagAttribute:
+		return cData.LibDM.GetTags(namespace)
+	}
+
+	return nil, nil
+}
+
+// Returns the tags or groups of a file
+func fileAttributeSlice(file *libdm.FileResponseItem, attribute libdm.Attribute) []string {
+	if attribute == libdm.GroupAttribute {
+		return file.Attributes.Groups
+	}
+
+	return file.Attributes.Tags
+}
+
+func attributeInSlice(name string, attributes []libdm.Attribute) bool {
 	for i := range attributes {
-		fmt.Println(attributes[i])
+		if string(attributes[i]) == name {
+			return true
+		}
 	}
+
+	return false
 }
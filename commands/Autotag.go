@@ -0,0 +1,300 @@
+package commands
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+	"github.com/dustin/go-humanize"
+)
+
+// AutotagRule assigns attributes to uploads matching all of its conditions.
+// A condition matches if any of its values matches. Rules without any
+// condition match every upload
+type AutotagRule struct {
+	// Conditions
+	Extensions []string // File extensions like '.jpg' or 'tar.gz'
+	Mimetypes  []string // Mimetypes like 'image/png' or 'image/*'
+	Paths      []string // Globs matching the full path or the filename
+	MinSize    string   // Minimum filesize like '10M'
+	MaxSize    string   // Maximum filesize like '1GB'
+	Hosts      []string // Hosts of uploaded urls like '*.github.com'
+
+	// Assignments
+	Tags       []string
+	Groups     []string
+	Namespace  string
+	Encryption string // Encrypt matching files using a generated key. Only aes is supported
+	Keysize    int    // Keysize of generated keys. Default 32
+
+	minSize, maxSize int64
+}
+
+// Information about an upload the rules are evaluated against
+type autotagItem struct {
+	names    []string
+	path     string
+	mimetype string
+	host     string
+	size     int64 // -1 if unknown
+}
+
+// Result of all matching rules
+type autotagResult struct {
+	tags, groups []string
+	namespace    string
+	encryption   string
+	keysize      int
+}
+
+// Parse and validate the rule
+func (rule *AutotagRule) init() error {
+	var err error
+	if rule.minSize, err = parseByteSize(rule.MinSize); err != nil {
+		return err
+	}
+
+	if rule.maxSize, err = parseByteSize(rule.MaxSize); err != nil {
+		return err
+	}
+
+	// Expand home directory
+	for i := range rule.Paths {
+		if strings.HasPrefix(rule.Paths[i], "~/") {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return err
+			}
+
+			rule.Paths[i] = filepath.Join(home, rule.Paths[i][2:])
+		}
+	}
+
+	if len(rule.Encryption) > 0 {
+		// Keys can only be generated for aes
+		if libdm.ChiperToInt(rule.Encryption) != EncryptionAES {
+			return errors.New("invalid encryption " + rule.Encryption + ". Only aes is supported")
+		}
+
+		if rule.Keysize == 0 {
+			rule.Keysize = 32
+		} else if rule.Keysize != 16 && rule.Keysize != 24 && rule.Keysize != 32 {
+			return errors.New("invalid keysize")
+		}
+	}
+
+	return nil
+}
+
+// Return true if item matches all conditions of the rule
+func (rule *AutotagRule) matches(item *autotagItem) bool {
+	if len(rule.Extensions) > 0 && !matchAny(rule.Extensions, func(ext string) bool {
+		ext = "." + strings.TrimPrefix(strings.ToLower(ext), ".")
+		for _, name := range item.names {
+			if strings.HasSuffix(strings.ToLower(name), ext) {
+				return true
+			}
+		}
+		return false
+	}) {
+		return false
+	}
+
+	if len(rule.Mimetypes) > 0 && !matchAny(rule.Mimetypes, func(mimetype string) bool {
+		return len(item.mimetype) > 0 && globMatch(strings.ToLower(mimetype), item.mimetype)
+	}) {
+		return false
+	}
+
+	if len(rule.Paths) > 0 && !matchAny(rule.Paths, func(pattern string) bool {
+		// Patterns without a slash only match the filename
+		if !strings.Contains(pattern, "/") {
+			for _, name := range item.names {
+				if globMatch(pattern, name) {
+					return true
+				}
+			}
+			return false
+		}
+
+		return len(item.path) > 0 && globMatch(pattern, item.path)
+	}) {
+		return false
+	}
+
+	if len(rule.Hosts) > 0 && !matchAny(rule.Hosts, func(host string) bool {
+		return len(item.host) > 0 && globMatch(strings.ToLower(host), item.host)
+	}) {
+		return false
+	}
+
+	// Size conditions never match unknown sizes
+	if rule.minSize > 0 && (item.size < 0 || item.size < rule.minSize) {
+		return false
+	}
+
+	if rule.maxSize > 0 && (item.size < 0 || item.size > rule.maxSize) {
+		return false
+	}
+
+	return true
+}
+
+// Evaluate all autotag rules for the item. Tags and groups of all
+// matching rules are combined, namespace and encryption are taken
+// from the first matching rule specifying them
+func (cData *CommandData) evalAutotagRules(item *autotagItem) *autotagResult {
+	if cData.CLIConfig == nil || len(cData.CLIConfig.Autotag) == 0 {
+		return nil
+	}
+
+	var result *autotagResult
+	for i := range cData.CLIConfig.Autotag {
+		rule := &cData.CLIConfig.Autotag[i]
+		if !rule.matches(item) {
+			continue
+		}
+
+		if result == nil {
+			result = &autotagResult{}
+		}
+
+		result.tags = appendUnique(result.tags, rule.Tags...)
+		result.groups = appendUnique(result.groups, rule.Groups...)
+
+		if len(result.namespace) == 0 {
+			result.namespace = rule.Namespace
+		}
+
+		if len(result.encryption) == 0 && len(rule.Encryption) > 0 {
+			result.encryption = rule.Encryption
+			result.keysize = rule.Keysize
+		}
+	}
+
+	return result
+}
+
+// Merge the result into attributes. The rules namespace is only
+// used if keepNamespace is false (no namespace was set explicitly)
+func (result *autotagResult) apply(attributes *libdm.FileAttributes, keepNamespace bool) {
+	attributes.Tags = appendUnique(append([]string{}, attributes.Tags...), result.tags...)
+	attributes.Groups = appendUnique(append([]string{}, attributes.Groups...), result.groups...)
+
+	if len(result.namespace) > 0 && !keepNamespace {
+		attributes.Namespace = result.namespace
+	}
+}
+
+// Build the autotag item for an upload
+func (uploadData *UploadData) autotagItem(uri string) *autotagItem {
+	item := &autotagItem{
+		names: []string{uploadData.Name},
+		size:  -1,
+	}
+
+	switch {
+	case uploadData.FromStdIn:
+		item.mimetype = mime.TypeByExtension(filepath.Ext(uploadData.Name))
+	case isHTTPURL(uri):
+		u, _ := url.Parse(uri)
+		item.host = strings.ToLower(u.Hostname())
+		item.path = u.Path
+		item.names = append(item.names, filepath.Base(u.Path))
+		item.mimetype = mime.TypeByExtension(filepath.Ext(u.Path))
+	default:
+		item.path = uri
+		item.names = append(item.names, filepath.Base(uri))
+
+		if uploadData.uploadAsArchive {
+			item.mimetype = archiveMimetype(uploadData.ArchiveFormat)
+		} else {
+			if s, err := os.Stat(uri); err == nil {
+				item.size = s.Size()
+			}
+			item.mimetype = detectMimetype(uri)
+		}
+	}
+
+	// Strip parameters like charset
+	item.mimetype = strings.ToLower(strings.TrimSpace(strings.Split(item.mimetype, ";")[0]))
+
+	return item
+}
+
+// Generate a key for a single upload encrypted by an autotag rule
+func (cData *CommandData) genAutotagKey(keysize int) ([]byte, string, error) {
+	key := make([]byte, keysize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, "", err
+	}
+
+	// Without a keystore the key would be lost
+	keystore, _ := cData.GetKeystore()
+	if keystore == nil {
+		return nil, "", errors.New("encryption by autotag rules requires a keystore")
+	}
+
+	keyFile := filepath.Join(keystore.Path, "key"+gaw.RandString(7))
+	for gaw.FileExists(keyFile) {
+		keyFile = filepath.Join(keystore.Path, "key"+gaw.RandString(7))
+	}
+
+	return key, keyFile, ioutil.WriteFile(keyFile, key, 0600)
+}
+
+// Detect the mimetype of a local file by its extension
+// or if unknown by its content
+func detectMimetype(file string) string {
+	if mimetype := mime.TypeByExtension(filepath.Ext(file)); len(mimetype) > 0 {
+		return mimetype
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	buff := make([]byte, 512)
+	n, _ := f.Read(buff)
+	return http.DetectContentType(buff[:n])
+}
+
+// Parse sizes like '10M' or '1.5GiB'. Empty strings result in 0
+func parseByteSize(s string) (int64, error) {
+	if len(strings.TrimSpace(s)) == 0 {
+		return 0, nil
+	}
+
+	size, err := humanize.ParseBytes(s)
+	return int64(size), err
+}
+
+func matchAny(values []string, match func(string) bool) bool {
+	for i := range values {
+		if match(values[i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func appendUnique(slice []string, items ...string) []string {
+	for i := range items {
+		if !gaw.IsInStringArray(items[i], slice) {
+			slice = append(slice, items[i])
+		}
+	}
+
+	return slice
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/JojiiOfficial/configService"
+)
+
+// CLIConfigFile file containing settings only used by the CLI.
+// It's kept next to the main config, since saving the main
+// config would drop all fields it doesn't know about
+const CLIConfigFile = "cli.yaml"
+
+// CLIConfig settings only used by the CLI
+type CLIConfig struct {
+	File    string `yaml:"-"`
+	Autotag []AutotagRule
+	Hooks   map[string][]string
+	Aliases map[string]Alias
+	Retry   RetryPolicy
+	Ignore  []string // Patterns of files to ignore in uploaded directories
+	Limits  struct {
+		Rate, Upload, Download string // Default bandwidth limits like '5M'
+	}
+	Audit struct {
+		MaxAge        int      // Days a file may be public
+		SensitiveTags []string // Tags of files which shouldn't be public
+	}
+}
+
+// Alias a command or a list of commands (macro) which are run in sequence
+type Alias []string
+
+// UnmarshalYAML allows using a single command instead of a list
+func (alias *Alias) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var command string
+	if err := unmarshal(&command); err == nil {
+		*alias = Alias{command}
+		return nil
+	}
+
+	var commands []string
+	if err := unmarshal(&commands); err != nil {
+		return err
+	}
+
+	*alias = commands
+	return nil
+}
+
+// LoadCLIConfig loads the CLI config located next to mainConfigFile.
+// A missing file results in an empty config
+func LoadCLIConfig(mainConfigFile string) (*CLIConfig, error) {
+	config := CLIConfig{
+		File: filepath.Join(filepath.Dir(mainConfigFile), CLIConfigFile),
+	}
+
+	if _, err := os.Stat(config.File); err != nil {
+		return &config, nil
+	}
+
+	if err := configService.Load(&config, config.File); err != nil {
+		return nil, err
+	}
+
+	// Validate autotag rules
+	for i := range config.Autotag {
+		if err := config.Autotag[i].init(); err != nil {
+			return nil, fmt.Errorf("%s: autotag rule %d: %s", config.File, i+1, err)
+		}
+	}
+
+	if err := validateHooks(config.Hooks); err != nil {
+		return nil, fmt.Errorf("%s: %s", config.File, err)
+	}
+
+	if _, err := parseIgnorePatterns(config.Ignore, ""); err != nil {
+		return nil, fmt.Errorf("%s: ignore: %s", config.File, err)
+	}
+
+	if err := config.Retry.init(); err != nil {
+		return nil, fmt.Errorf("%s: retry: %s", config.File, err)
+	}
+
+	for name, alias := range config.Aliases {
+		if len(alias) == 0 {
+			return nil, fmt.Errorf("%s: alias '%s' is empty", config.File, name)
+		}
+	}
+
+	return &config, nil
+}
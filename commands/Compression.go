@@ -0,0 +1,260 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// Compression algorithms
+const (
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+	CompressionXz   = "xz"
+)
+
+// CompressionAlgorithms all supported compression algorithms
+var CompressionAlgorithms = []string{CompressionGzip, CompressionZstd, CompressionXz}
+
+// Magic bytes of the compression formats
+var (
+	gzipMagic = []byte{0x1f, 0x8b, 0x08}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	xzMagic   = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+)
+
+// Max amount of bytes required to detect a format
+const magicLen = 6
+
+// Dictionary sizes of the xz presets 0-9. Levels
+// for xz only select the dictionary size
+var xzDictCaps = []int{1 << 18, 1 << 20, 1 << 21, 1 << 22, 1 << 22, 1 << 23, 1 << 23, 1 << 24, 1 << 25, 1 << 26}
+
+// Returns the file suffix of a compression algorithm
+func compressionSuffix(algorithm string) string {
+	switch algorithm {
+	case CompressionGzip:
+		return ".gz"
+	case CompressionZstd:
+		return ".zst"
+	case CompressionXz:
+		return ".xz"
+	}
+
+	return ""
+}
+
+// CheckCompressionLevel returns an error if level is
+// invalid for algorithm. 0 uses the default level
+func CheckCompressionLevel(algorithm string, level int) error {
+	if len(algorithm) == 0 {
+		if level != 0 {
+			return errors.New("--compression-level requires --compression")
+		}
+		return nil
+	}
+
+	max := 0
+	switch algorithm {
+	case CompressionGzip, CompressionXz:
+		max = 9
+	case CompressionZstd:
+		max = 22
+	}
+
+	if level < 0 || level > max {
+		return fmt.Errorf("%s supports levels from 1 to %d (0 uses the default level)", algorithm, max)
+	}
+
+	return nil
+}
+
+// Detect the compression algorithm of data by its magic bytes
+func detectCompression(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, gzipMagic):
+		return CompressionGzip
+	case bytes.HasPrefix(header, zstdMagic):
+		return CompressionZstd
+	case bytes.HasPrefix(header, xzMagic):
+		return CompressionXz
+	}
+
+	return ""
+}
+
+// Create a writer compressing all data into w
+func newCompressor(w io.Writer, algorithm string, level int) (io.WriteCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return gzip.NewWriterLevel(w, level)
+	case CompressionZstd:
+		if level == 0 {
+			return zstd.NewWriter(w)
+		}
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	case CompressionXz:
+		config := xz.WriterConfig{}
+		if level > 0 {
+			config.DictCap = xzDictCaps[level]
+		}
+		return config.NewWriter(w)
+	}
+
+	return nil, fmt.Errorf("unsupported compression '%s'", algorithm)
+}
+
+// Create a reader decompressing r. Closing it
+// releases the resources of the decompressor
+func newDecompressor(r io.Reader, algorithm string) (io.ReadCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		decoder, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	case CompressionXz:
+		xr, err := xz.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(xr), nil
+	}
+
+	return nil, fmt.Errorf("unsupported compression '%s'", algorithm)
+}
+
+// Returns a reader containing the compressed data of r.
+// Closing it stops the compression
+func compressReader(r io.Reader, algorithm string, level int) io.ReadCloser {
+	pr, pw := io.Pipe()
+
+	go func() {
+		compressor, err := newCompressor(pw, algorithm, level)
+		if err == nil {
+			_, err = io.Copy(compressor, r)
+			if cerr := compressor.Close(); err == nil {
+				err = cerr
+			}
+		}
+
+		pw.CloseWithError(err)
+	}()
+
+	return pr
+}
+
+// extractWriter detects the compression of the written data by
+// its magic bytes and writes the decompressed data into w.
+// Uncompressed data gets written as it is
+type extractWriter struct {
+	w      io.Writer
+	header []byte
+	out    io.Writer
+	pw     *io.PipeWriter
+	done   chan error
+}
+
+func newExtractWriter(w io.Writer) *extractWriter {
+	return &extractWriter{w: w}
+}
+
+func (ew *extractWriter) Write(b []byte) (int, error) {
+	if ew.out != nil {
+		return ew.out.Write(b)
+	}
+
+	// Collect the header
+	n := magicLen - len(ew.header)
+	if n > len(b) {
+		n = len(b)
+	}
+	ew.header = append(ew.header, b[:n]...)
+
+	if len(ew.header) < magicLen {
+		return len(b), nil
+	}
+
+	if err := ew.start(); err != nil {
+		return 0, err
+	}
+
+	written, err := ew.out.Write(b[n:])
+	return written + n, err
+}
+
+// Detect the format and write the collected header
+func (ew *extractWriter) start() error {
+	ew.out = ew.w
+
+	if algorithm := detectCompression(ew.header); len(algorithm) > 0 {
+		pr, pw := io.Pipe()
+		ew.pw = pw
+		ew.out = pw
+		ew.done = make(chan error, 1)
+
+		go func() {
+			r, err := newDecompressor(pr, algorithm)
+			if err == nil {
+				_, err = io.Copy(ew.w, r)
+				r.Close()
+			}
+
+			pr.CloseWithError(err)
+			ew.done <- err
+		}()
+	}
+
+	_, err := ew.out.Write(ew.header)
+	return err
+}
+
+// Returns true if the written data was compressed
+func (ew *extractWriter) decompressed() bool {
+	return ew.pw != nil
+}
+
+// Close writes remaining data and waits for the decompression
+func (ew *extractWriter) Close() error {
+	if ew.out == nil {
+		if err := ew.start(); err != nil {
+			return err
+		}
+	}
+
+	if ew.pw == nil {
+		return nil
+	}
+
+	ew.pw.Close()
+	return <-ew.done
+}
+
+// Files compressed by older versions were encrypted before being
+// gzipped. Those have to be extracted before they can be decrypted
+func extractLegacyProxy(resp *libdm.FileDownloadResponse, proxy libdm.ReaderProxy) libdm.ReaderProxy {
+	return func(r io.Reader) io.Reader {
+		br := bufio.NewReader(proxy(r))
+
+		if len(resp.Encryption) > 0 {
+			header, _ := br.Peek(len(gzipMagic))
+			resp.Extract = bytes.Equal(header, gzipMagic)
+		}
+
+		return br
+	}
+}
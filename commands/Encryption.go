@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"crypto/aes"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+)
+
+// Encryption ciphers as stored in libdm.FileResponseItem.Encryption
+const (
+	EncryptionNone int8 = 0
+	EncryptionAES  int8 = 1
+	EncryptionAGE  int8 = 2
+)
+
+// AES prepends its IV to the encrypted content
+const aesOverhead = aes.BlockSize
+
+// Returns false if the size of the decrypted file can't be
+// determined, which is the case for age as its overhead isn't fixed
+func plainSizeKnown(file *libdm.FileResponseItem) bool {
+	return file.Encryption == EncryptionNone || file.Encryption == EncryptionAES
+}
+
+// Returns the size of the decrypted file if
+// it can be determined, otherwise 0
+func plainFileSize(file *libdm.FileResponseItem) int64 {
+	if file.Encryption == EncryptionAES {
+		return file.Size - aesOverhead
+	}
+
+	if !plainSizeKnown(file) {
+		return 0
+	}
+
+	return file.Size
+}
@@ -56,6 +56,11 @@ func DeleteFile(cData *CommandData, name string, id uint) {
 		keystore, _ := cData.GetKeystore()
 		rmFilesFromkeystore(keystore, resp.IDs)
 	}
+
+	// rm files from the local index
+	if err := cData.index.remove(resp.IDs); err != nil {
+		printWarning("updating index", err.Error())
+	}
 }
 
 // ListFiles lists the files corresponding to the args
@@ -64,12 +69,21 @@ func ListFiles(cData *CommandData, name string, id uint, sOrder string) {
 	name, id = GetFileCommandData(name, id)
 
 	// Do ListFile request
-	resp, err := cData.LibDM.ListFiles(name, id, cData.All, cData.FileAttributes, cData.Details)
+	// Groups are required to detect parts of split files
+	verbose := cData.Details
+	if verbose < 2 {
+		verbose = 2
+	}
+
+	resp, err := cData.listFiles(name, id, cData.All, cData.FileAttributes, verbose)
 	if err != nil {
 		printResponseError(err, "listing files")
 		return
 	}
 
+	// Show split files as a single file
+	resp.Files = collapseSplitFiles(resp.Files)
+
 	// Request user confirmation if files are too much
 	if !IsPiped() && uint16(len(resp.Files)) > cData.Config.Client.MinFilesToDisplay && !cData.Yes {
 		if y, _ := gaw.ConfirmInput("Do you want to view all? (y/n) > ", bufio.NewReader(os.Stdin)); !y {
@@ -217,7 +231,7 @@ func ListFiles(cData *CommandData, name string, id uint, sOrder string) {
 }
 
 // PublishFile publishes a file
-func PublishFile(cData *CommandData, name string, id uint, publicName string, setClip bool) {
+func PublishFile(cData *CommandData, name string, id uint, publicName string, setClip bool, qr QROptions) {
 	// Convert input
 	name, id = GetFileCommandData(name, id)
 
@@ -226,38 +240,109 @@ func PublishFile(cData *CommandData, name string, id uint, publicName string, se
 		return
 	}
 
-	resp, err := cData.LibDM.PublishFile(name, id, publicName, cData.All, cData.FileAttributes)
+	if cData.All && qr.Enabled() {
+		fmt.Println("You can't create QR codes of multiple files")
+		return
+	}
+
+	// Get the files to scan them before publishing. '%' matches all files
+	listName := name
+	if strings.TrimSpace(name) == "%" {
+		listName = ""
+	}
+
+	files, err := cData.listFiles(listName, id, false, cData.FileAttributes, 2)
+	if err != nil {
+		printResponseError(err, "listing files")
+		return
+	}
+
+	if len(files.Files) == 0 {
+		fmt.Println("No files found")
+		return
+	}
+
+	if cData.All {
+		cData.publishFiles(files.Files)
+		return
+	}
+
+	if len(files.Files) > 1 {
+		fmt.Printf("Found %d files matching '%s'. Use the fileID or --all to publish all of them\n", len(files.Files), name)
+		return
+	}
+
+	if !cData.checkPublish(&files.Files[0]) {
+		os.Exit(1)
+	}
+
+	resp, err := cData.LibDM.PublishFile(name, id, publicName, false, cData.FileAttributes)
 	if err != nil || resp == nil {
 		printResponseError(err, "publishing file")
 		return
 	}
 
+	rs := (resp).(libdm.BulkPublishResponse)
+
+	// Create the QR code of the public url
+	var qrCode string
+	if qr.Enabled() && len(rs.Files) > 0 {
+		if qrCode, err = cData.publicURLQR(rs.Files[0].PublicFilename, qr); err != nil {
+			printError("creating QR code", err.Error())
+		}
+	}
+
 	// Output
 	if cData.OutputJSON {
-		fmt.Println(toJSON(resp))
+		if len(qrCode) == 0 {
+			fmt.Println(toJSON(resp))
+			return
+		}
+
+		files := make([]qrUploadResponse, len(rs.Files))
+		for i := range rs.Files {
+			files[i] = qrUploadResponse{UploadResponse: &rs.Files[i]}
+		}
+		files[0].QRCode = qrCode
+
+		fmt.Println(toJSON(struct {
+			Files []qrUploadResponse `json:"files"`
+		}{files}))
 	} else {
-		if cData.All {
-			rs := (resp).(libdm.BulkPublishResponse)
+		fmt.Printf(cData.Config.GetPreviewURL(rs.Files[0].PublicFilename))
 
-			fmt.Printf("Published %d files\n", len(rs.Files))
-			for _, file := range rs.Files {
-				fmt.Printf("File %s with ID %d Public name: %s\n", file.Filename, file.FileID, file.PublicFilename)
-			}
-		} else {
-			rs := (resp).(libdm.BulkPublishResponse)
-			fmt.Printf(cData.Config.GetPreviewURL(rs.Files[0].PublicFilename))
+		if setClip {
+			cData.setClipboard(rs.Files[0].PublicFilename)
+		}
 
-			if setClip {
-				cData.setClipboard(rs.Files[0].PublicFilename)
-			}
+		if len(qrCode) > 0 {
+			fmt.Printf("\n%s\n", qrCode)
 		}
 	}
 }
 
-// UnPublishFile makes a public file private
-func UnPublishFile(cData *CommandData, name string, id uint) {
+// UnPublishFile makes a public file private. Using --all and a non
+// empty filter unpublishes the selected files of all namespaces
+func UnPublishFile(cData *CommandData, name string, id uint, filter AuditFilter) {
 	// Convert input
 	name, id = GetFileCommandData(name, id)
+	ProcesStrSliceParam(&filter.SensitiveTags)
+
+	if !filter.Empty() {
+		if !cData.All || id > 0 {
+			fmt.Println("Filters can only be used with --all")
+			return
+		}
+
+		cData.unpublishFiles(name, filter)
+		return
+	}
+
+	if len(name) == 0 && id == 0 {
+		fmtError("Missing a valid parameter. Provide fileID, Filename or use --all with --older-than or --sensitive-tag")
+		return
+	}
+
 	UpdateFile(cData, name, id, "", "", []string{}, []string{}, []string{}, []string{}, false, true)
 }
 
@@ -275,7 +360,7 @@ func UpdateFile(cData *CommandData, name string, id uint, newName string, newNam
 		return
 	}
 
-	response, err := cData.LibDM.UpdateFile(name, id, cData.Namespace, cData.All, libdm.FileChanges{
+	response, err := cData.updateFile(name, id, cData.Namespace, cData.All, libdm.FileChanges{
 		NewName:      newName,
 		NewNamespace: newNamespace,
 		AddTags:      addTags,
@@ -373,7 +458,7 @@ func (cData *CommandData) CreateFile(name string) {
 	success = true
 	cData.printUploadResponse(resp, &UploadData{
 		Name: name,
-	}, cData.Quiet, nil)
+	}, cData.Quiet, nil, "")
 }
 
 // FileTree shows a unix tree like view of files
@@ -386,7 +471,7 @@ func (cData *CommandData) FileTree(sOrder, namespace string) {
 	cData.All = len(cData.FileAttributes.Namespace) == 0
 
 	// Do file list request
-	resp, err := cData.LibDM.ListFiles("", 0, cData.All, cData.FileAttributes, 3)
+	resp, err := cData.listFiles("", 0, cData.All, cData.FileAttributes, 3)
 	if err != nil {
 		printResponseError(err, "getting files")
 		return
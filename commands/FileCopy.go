@@ -0,0 +1,276 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+	"github.com/JojiiOfficial/gopool"
+)
+
+// CopyFiles copies the file(s) matching name/id into newNamespace
+func (cData *CommandData) CopyFiles(name string, id uint, newNamespace string, threads int) {
+	// Convert input
+	name, id = GetFileCommandData(name, id)
+
+	if len(newNamespace) == 0 {
+		fmtError("Missing the namespace to copy the file(s) to")
+		return
+	}
+
+	if len(name) == 0 && id == 0 && !cData.All {
+		fmtError("Missing a valid parameter. Provide fileID, Filename or use --all")
+		return
+	}
+
+	// Get files to copy
+	resp, err := cData.listFiles(name, id, false, cData.FileAttributes, 2)
+	if err != nil {
+		printResponseError(err, "listing files")
+		return
+	}
+
+	if len(resp.Files) == 0 {
+		fmt.Println("No files found")
+		return
+	}
+
+	if len(resp.Files) > 1 && !cData.All {
+		fmt.Printf("Found %d files matching '%s'. Use the fileID or --all to copy all of them\n", len(resp.Files), name)
+		return
+	}
+
+	cData.copyFiles(resp.Files, newNamespace, threads)
+}
+
+// CloneNamespace copies all files of srcNamespace into dstNamespace
+func (cData *CommandData) CloneNamespace(srcNamespace, dstNamespace string, threads int) {
+	// Get all files in source namespace
+	resp, err := cData.listFiles("", 0, false, libdm.FileAttributes{
+		Namespace: srcNamespace,
+	}, 2)
+	if err != nil {
+		printResponseError(err, "listing files")
+		return
+	}
+
+	if len(resp.Files) == 0 {
+		fmt.Printf("No files in namespace %s\n", srcNamespace)
+		return
+	}
+
+	// Create destination namespace if required
+	exists, err := cData.namespaceExists(dstNamespace)
+	if err != nil {
+		printResponseError(err, "listing namespaces")
+		return
+	}
+
+	if !exists {
+		if _, err = cData.LibDM.CreateNamespace(dstNamespace); err != nil {
+			printResponseError(err, "creating namespace")
+			return
+		}
+
+		if !cData.Quiet {
+			fmt.Printf("Created namespace '%s'\n", dstNamespace)
+		}
+	}
+
+	cData.copyFiles(resp.Files, dstNamespace, threads)
+}
+
+// Split files are linked by the file IDs in their manifest
+const splitCopyReason = "split files can't be copied"
+
+// Copy files into newNamespace using threads parallel copies
+func (cData *CommandData) copyFiles(files []libdm.FileResponseItem, newNamespace string, threads int) {
+	// Each copy uses a download and an upload connection
+	cData.LibDM.MaxConnectionsPerHost = threads * 2
+
+	uploadData := &UploadData{
+		TotalFiles:   len(files),
+		ProgressView: NewProgressView(),
+	}
+
+	// Use the longest filename to align the bars
+	for i := range files {
+		if len(files[i].Name) > uploadData.maxItemLen {
+			uploadData.maxItemLen = len(files[i].Name)
+		}
+	}
+
+	report := newTransferReport(cData.FailFast)
+	uploadData.showReport = len(files) > 1 && !cData.FailFast
+
+	gopool.New(len(files), threads, func(wg *sync.WaitGroup, pos, total, workerID int) interface{} {
+		file := files[pos]
+
+		if report.shouldSkip() {
+			report.skipped(file.Name, file.ID, "previous copy failed")
+			return nil
+		}
+
+		// A copied manifest would still point to the source parts
+		if isSplitManifest(file.Name) || len(splitGroup(&file)) > 0 {
+			if !uploadData.showReport {
+				printWarning(fmt.Sprintf("copying '%s'", file.Name), splitCopyReason)
+			}
+			report.skipped(file.Name, file.ID, splitCopyReason)
+			return nil
+		}
+
+		if err := cData.copyFile(file, newNamespace, *uploadData); err != nil {
+			report.failed(file.Name, file.ID, err)
+		} else {
+			report.succeeded(file.Name, file.ID, file.Size)
+		}
+
+		return nil
+	}).Run().Wait()
+
+	uploadData.ProgressView.awaitBars()
+
+	if uploadData.showReport {
+		cData.printTransferReport(report)
+	}
+
+	if report.hasFailed() {
+		os.Exit(1)
+	}
+}
+
+// Copy a single file by streaming its
+// download directly into a new upload
+func (cData *CommandData) copyFile(file libdm.FileResponseItem, newNamespace string, uploadData UploadData) error {
+	uploadData.Name = file.Name
+
+	// Get the key to re-encrypt the
+	// file with the same key
+	var key []byte
+	var keyFromKeystore bool
+	if file.Encryption > 0 {
+		key, keyFromKeystore = cData.getFileKey(file.ID)
+		if len(key) == 0 {
+			printError(fmt.Sprintf("copying '%s'", file.Name), libdm.ErrFileEncrypted.Error())
+			return libdm.ErrFileEncrypted
+		}
+	}
+
+	// Request the source file
+	resp, err := cData.LibDM.NewFileRequest(file.ID, "", file.Attributes.Namespace).Do()
+	if err != nil {
+		printResponseError(err, "requesting file")
+		return err
+	}
+	resp.DownloadRequest.DecryptWith(key)
+	resp.DownloadRequest.ReaderProxy = cData.limitDownload
+
+	// Build upload request preserving all attributes
+	uploadRequest := cData.LibDM.NewUploadRequest(file.Name, libdm.FileAttributes{
+		Namespace: newNamespace,
+		Tags:      file.Attributes.Tags,
+		Groups:    file.Attributes.Groups,
+	})
+
+	if file.Encryption > 0 {
+		uploadRequest.Encrypted(file.Encryption, key)
+	}
+
+	// Stream the download into the upload
+	pr, pw := io.Pipe()
+	dlErr := make(chan error, 1)
+	go func() {
+		err := resp.SaveTo(pw, nil)
+		pw.CloseWithError(err)
+		dlErr <- err
+	}()
+
+	// An unknown size is passed as 0, which
+	// lets the upload run without a known length
+	execUploader := cData.newUploader(&uploadData, "", uploadRequest, !cData.Quiet)
+	uploadResponse := execUploader.uploadFromReader(pr, plainFileSize(&file))
+
+	// Unblock download if upload failed
+	pr.Close()
+	if err := <-dlErr; err != nil && uploadResponse != nil {
+		printError("downloading file", err.Error())
+		return err
+	}
+
+	if uploadResponse == nil {
+		return execUploader.err
+	}
+
+	// Verify source checksum
+	if !cData.verifyChecksum(resp.LocalChecksum, resp.ServerChecksum) {
+		return libdm.ErrChecksumNotMatch
+	}
+
+	// Assign a copy of the key to the new file
+	if keyFromKeystore {
+		if err := cData.copyKeystoreKey(file.ID, uploadResponse.FileID); err != nil {
+			printError("writing keystore", err.Error())
+		}
+	}
+
+	cData.runPostUpload(&uploadData, uploadResponse, execUploader)
+
+	// Keep the attributes of the original local file
+	if err := cData.index.copyFileInfo(file.ID, uploadResponse.FileID); err != nil {
+		printWarning("writing index", err.Error())
+	}
+
+	return nil
+}
+
+// getFileKey returns the key to decrypt fileID with and
+// true if the key was taken from the keystore
+func (cData *CommandData) getFileKey(fileID uint) ([]byte, bool) {
+	if len(cData.EncryptionKey) > 0 {
+		return cData.EncryptionKey, false
+	}
+
+	if cData.HasKeystoreSupport() {
+		keystore, _ := cData.GetKeystore()
+		if key, err := keystore.GetKey(fileID); err == nil {
+			return key, true
+		}
+	}
+
+	return nil, false
+}
+
+// copyKeystoreKey assigns a copy of the key of srcID to
+// dstID. Every file gets its own keyfile since deleting a
+// file shredders its keyfile
+func (cData *CommandData) copyKeystoreKey(srcID, dstID uint) error {
+	keystore, err := cData.GetKeystore()
+	if err != nil || keystore == nil {
+		return err
+	}
+
+	key, err := keystore.GetKey(srcID)
+	if err != nil {
+		return err
+	}
+
+	// Find an unused keyfile name
+	var keyFile string
+	for {
+		keyFile = keystore.GetKeystoreFile("key" + gaw.RandString(7))
+		if !gaw.FileExists(keyFile) {
+			break
+		}
+	}
+
+	if err = ioutil.WriteFile(keyFile, key, 0600); err != nil {
+		return err
+	}
+
+	return keystore.AddKey(dstID, keyFile)
+}
@@ -1,10 +1,13 @@
 package commands
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -21,9 +24,17 @@ import (
 type DownloadData struct {
 	FileName  string
 	FileID    uint
+	Namespace string // Defaults to the namespace of the command
 	LocalPath string
 	Preview   bool
 
+	// Extract the downloaded archive into this directory
+	Unpack          string
+	StripComponents int
+
+	// Parts of a split file downloaded at the same time
+	Parallelism int
+
 	ProgressView *ProgressView
 }
 
@@ -51,6 +62,7 @@ func (cData *CommandData) ViewFile(downloadData *DownloadData) {
 		cData.previewFile(tmpFile)
 	} else {
 		// Display file in os.Stdout (terminal)
+		resp.DownloadRequest.ReaderProxy = cData.limitDownload
 		if err = resp.SaveTo(os.Stdout, nil); err != nil {
 			printError("downloading file", err.Error())
 			return
@@ -94,7 +106,12 @@ func (cData *CommandData) DownloadFile(downloadData *DownloadData) (*libdm.FileD
 	}
 
 	// Determine where the file should be stored in
-	outFile := resolveOutputFile(resp.ServerFileName, downloadData.LocalPath, cData.Extract)
+	var outFile string
+	if len(downloadData.Unpack) > 0 {
+		outFile = gaw.ResolveFullPath(downloadData.Unpack)
+	} else {
+		outFile = resolveOutputFile(resp.ServerFileName, downloadData.LocalPath, cData.Extract)
+	}
 
 	// Wait for bench result
 	// and save it to config
@@ -107,6 +124,11 @@ func (cData *CommandData) DownloadFile(downloadData *DownloadData) (*libdm.FileD
 		}
 	}
 
+	// Split files are put together from their parts
+	if isSplitManifest(resp.ServerFileName) {
+		return resp, cData.downloadSplitFile(downloadData, resp)
+	}
+
 	var bar *Bar
 	if !cData.Quiet {
 		// Create new progressview
@@ -121,19 +143,25 @@ func (cData *CommandData) DownloadFile(downloadData *DownloadData) (*libdm.FileD
 
 	// Prevent accidentally overwriting the file
 	// TODO add chechksum validation
-	if gaw.FileExists(outFile) && !cData.Force && !strings.HasPrefix(outFile, "/dev/") {
+	if len(downloadData.Unpack) > 0 {
+		if !cData.Force && !isEmptyDir(outFile) {
+			fmt.Printf("Directory '%s' is not empty. Use -f to unpack into it anyway\n", outFile)
+			return resp, ErrDirNotEmpty
+		}
+	} else if gaw.FileExists(outFile) && !cData.Force && !strings.HasPrefix(outFile, "/dev/") {
 		fmt.Printf("File '%s' already exists. Use -f to overwrite it or choose a different outputfile", outFile)
 		return resp, err
 	}
 
 	cancel := make(chan bool, 1)
 	c := make(chan string, 1)
+	var success bool
 
 	go func() {
 		// Save server file to local 'outFile'
-		if err = cData.writeFile(resp, outFile, cancel, bar); err != nil {
+		if err = downloadData.writeFileRetry(cData, &resp, outFile, cancel, bar); err != nil {
 			// Delete file on error. On checksum error only delete if --verify was passed
-			if err != libdm.ErrChecksumNotMatch || cData.VerifyFile {
+			if len(downloadData.Unpack) == 0 && (err != libdm.ErrChecksumNotMatch || cData.VerifyFile) {
 				ShredderFile(outFile, -1)
 			}
 
@@ -147,7 +175,11 @@ func (cData *CommandData) DownloadFile(downloadData *DownloadData) (*libdm.FileD
 	// Wait for download to be done or delete file on interrupt
 	awaitOrInterrupt(c, func(s os.Signal) {
 		if bar != nil {
-			bar.stop("Cancelled. Erasing file!")
+			if len(downloadData.Unpack) > 0 {
+				bar.stop("Cancelled")
+			} else {
+				bar.stop("Cancelled. Erasing file!")
+			}
 		}
 
 		cancel <- true
@@ -161,7 +193,10 @@ func (cData *CommandData) DownloadFile(downloadData *DownloadData) (*libdm.FileD
 		if len(s) > 0 {
 			text = fmt.Sprintf("%s %s: %s", color.HiRedString("Error"), "downloading file", s)
 		} else {
-			if outFile != "/dev/null" {
+			success = true
+			if len(downloadData.Unpack) > 0 {
+				text = fmt.Sprintf("unpacked into '%s'", outFile)
+			} else if outFile != "/dev/null" {
 				text = fmt.Sprintf("saved '%s'", outFile)
 			}
 		}
@@ -184,12 +219,37 @@ func (cData *CommandData) DownloadFile(downloadData *DownloadData) (*libdm.FileD
 		}
 	}
 
+	if !success {
+		return resp, err
+	}
+
+	// Archives contain the attributes of their members
+	if len(downloadData.Unpack) == 0 && !strings.HasPrefix(outFile, "/dev/") {
+		cData.restoreFileInfo(outFile, resp)
+	}
+
+	cData.runPostDownloadHooks(outFile, downloadData.namespace(cData), resp)
 	return resp, nil
 }
 
+// Returns the namespace to download the file from
+func (downloadData *DownloadData) namespace(cData *CommandData) string {
+	if len(downloadData.Namespace) > 0 {
+		return downloadData.Namespace
+	}
+
+	return cData.FileAttributes.Namespace
+}
+
 func (downloadData *DownloadData) doRequest(cData *CommandData, showBar bool) (*libdm.FileDownloadResponse, error) {
+	namespace := downloadData.namespace(cData)
+
 	// Create new filerequest
-	resp, err := cData.LibDM.NewFileRequest(downloadData.FileID, downloadData.FileName, cData.FileAttributes.Namespace).Do()
+	var resp *libdm.FileDownloadResponse
+	err := cData.retry(func() (err error) {
+		resp, err = cData.LibDM.NewFileRequest(downloadData.FileID, downloadData.FileName, namespace).Do()
+		return err
+	}, cData.printRetry("requesting file"))
 	if err != nil {
 		return nil, err
 	}
@@ -210,40 +270,142 @@ func (cData *CommandData) handleDecryption(resp *libdm.FileDownloadResponse) {
 
 // Write response to a given file
 func (cData *CommandData) writeFile(resp *libdm.FileDownloadResponse, file string, cancel chan bool, bar *Bar) error {
-	if bar != nil {
-		resp.DownloadRequest.ReaderProxy = func(r io.Reader) io.Reader {
-			return barProxy{
-				bar: bar,
-				r:   r,
-				d:   make(chan struct{}, 1),
-			}
-		}
+	err := cData.saveFile(resp, file, cancel, bar)
+	if err != nil {
+		cData.printDownloadError(resp, err, bar)
 	}
 
-	// Set extract
-	resp.Extract = cData.Extract
+	return err
+}
+
+// Write response to a given file retrying failed downloads.
+// resp gets replaced by the response of the last attempt
+func (downloadData *DownloadData) writeFileRetry(cData *CommandData, resp **libdm.FileDownloadResponse, file string, cancel chan bool, bar *Bar) error {
+	var retried bool
+	err := cData.retry(func() error {
+		// Request file again
+		if retried {
+			newResp, err := downloadData.doRequest(cData, bar != nil)
+			if err != nil {
+				return err
+			}
+			*resp = newResp
+		}
+		retried = true
 
-	// Save file to tempFile
-	if err := resp.WriteToFile(file, 0600, cancel); err != nil {
-		// Make error readable
-		var errText string
-		if err == libdm.ErrChecksumNotMatch {
-			errText = cData.getChecksumError(resp.LocalChecksum, resp.ServerChecksum)
-		} else {
-			errText = getError("downloading file", err.Error())
+		if len(downloadData.Unpack) > 0 {
+			return cData.unpackFile(*resp, file, downloadData.StripComponents, cancel, bar)
 		}
 
-		// View the error
+		return cData.saveFile(*resp, file, cancel, bar)
+	}, func(attempt, attempts int, err error) {
 		if bar != nil {
-			bar.doneTextChan <- errText
+			bar.retry(attempt, attempts)
 		} else {
-			fmt.Println(errText)
+			cData.printRetry("downloading file")(attempt, attempts, err)
+		}
+	})
+
+	if err != nil {
+		cData.printDownloadError(*resp, err, bar)
+	}
+
+	return err
+}
+
+// Returns the proxy for reading a download
+func (cData *CommandData) downloadProxy(bar *Bar) libdm.ReaderProxy {
+	// Respect bandwidth limits
+	if bar == nil {
+		return cData.limitDownload
+	}
+
+	return func(r io.Reader) io.Reader {
+		return barProxy{
+			bar: bar,
+			r:   cData.limitDownload(r),
+			d:   make(chan struct{}, 1),
 		}
+	}
+}
 
+// Save response into file
+func (cData *CommandData) saveFile(resp *libdm.FileDownloadResponse, file string, cancel chan bool, bar *Bar) error {
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Save file to tempFile
+	digest, extracted, err := saveResponse(resp, f, cData.downloadProxy(bar), cData.Extract, cancel)
+	if err != nil {
 		return err
 	}
 
-	return nil
+	if !resp.VerifyChecksum() {
+		return libdm.ErrChecksumNotMatch
+	}
+
+	return cData.verifyDigest(resp, digest, extracted)
+}
+
+// Extract the archive in resp into dir
+func (cData *CommandData) unpackFile(resp *libdm.FileDownloadResponse, dir string, strip int, cancel chan bool, bar *Bar) error {
+	return cData.readArchive(resp, cancel, bar, newUnpacker(dir, strip).unpack)
+}
+
+// Write the content of resp into w. If extract is true, compressed content
+// gets decompressed. Returns the SHA-256 digest of the written content
+// and whether it was decompressed
+func saveResponse(resp *libdm.FileDownloadResponse, w io.Writer, proxy libdm.ReaderProxy, extract bool, cancel chan bool) (hash.Hash, bool, error) {
+	digest := sha256.New()
+	w = io.MultiWriter(w, digest)
+
+	resp.DownloadRequest.ReaderProxy = proxy
+	resp.Extract = false
+
+	// Detect and extract compressed content
+	var extractor *extractWriter
+	if extract {
+		resp.DownloadRequest.ReaderProxy = extractLegacyProxy(resp, proxy)
+		extractor = newExtractWriter(w)
+		w = extractor
+	}
+
+	err := resp.SaveTo(w, cancel)
+
+	extracted := resp.Extract
+	if extractor != nil {
+		if cerr := extractor.Close(); err == nil {
+			err = cerr
+		}
+		extracted = extracted || extractor.decompressed()
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return digest, extracted, nil
+}
+
+// Print an error of a failed download
+func (cData *CommandData) printDownloadError(resp *libdm.FileDownloadResponse, err error, bar *Bar) {
+	// Make error readable
+	var errText string
+	if err == libdm.ErrChecksumNotMatch {
+		errText = cData.getChecksumError(resp.LocalChecksum, resp.ServerChecksum)
+	} else {
+		errText = getError("downloading file", err.Error())
+	}
+
+	// View the error
+	if bar != nil {
+		bar.doneTextChan <- errText
+	} else {
+		fmt.Fprintln(cData.messageWriter(), errText)
+	}
 }
 
 // Download multiple files into a folder
@@ -269,12 +431,26 @@ func (cData *CommandData) downloadFiles(files []libdm.FileResponseItem, outDir s
 	// Overwrite files
 	cData.Force = true
 
+	report := newTransferReport(cData.FailFast)
+
 	// Create and execute a new pool
 	gopool.New(len(files), threads, func(wg *sync.WaitGroup, pos, total, workerID int) interface{} {
 		file := files[pos]
 
+		if report.shouldSkip() {
+			report.skipped(file.Name, file.ID, "previous download failed")
+			return nil
+		}
+
 		// Build dest group dir name
 		dir := getSubDirName(file)
+		localPath := filepath.Join(rootDir, dir)
+
+		// Restore the tree of files named by their path
+		if pathDir, name, ok := splitStoredPath(file.Name); ok {
+			dir = filepath.FromSlash(pathDir)
+			localPath = filepath.Join(rootDir, dir, cData.handleFileEnding(name))
+		}
 
 		// Create dir if not exists
 		path := filepath.Clean(filepath.Join(rootDir, dir))
@@ -282,28 +458,69 @@ func (cData *CommandData) downloadFiles(files []libdm.FileResponseItem, outDir s
 			err := os.MkdirAll(path, 0750)
 			if err != nil {
 				printError("Creating dir", err.Error())
-				os.Exit(1)
+				report.failed(file.Name, file.ID, err)
+				return nil
 			}
 		}
 
-		// Download file
+		// Download file. Errors were already printed
 		if _, err := cData.DownloadFile(&DownloadData{
 			FileName:     file.Name,
 			FileID:       file.ID,
-			LocalPath:    filepath.Join(rootDir, dir),
+			Namespace:    file.Attributes.Namespace,
+			LocalPath:    localPath,
 			ProgressView: progressView,
 		}); err != nil {
-			printError("downloading namespace", err.Error())
-			os.Exit(1)
+			report.failed(file.Name, file.ID, err)
+		} else {
+			report.succeeded(file.Name, file.ID, file.Size)
 		}
 
 		return nil
 	}).Run().Wait()
+
+	if len(files) > 1 && !cData.FailFast {
+		cData.printTransferReport(report)
+	}
+
+	if report.hasFailed() {
+		os.Exit(1)
+	}
+}
+
+// Split the name of a file uploaded using --keep-paths into its
+// directory and base name. Returns false if name has no valid path
+func splitStoredPath(name string) (string, string, bool) {
+	if !strings.Contains(name, "/") {
+		return "", "", false
+	}
+
+	name = stripComponents(name, 0)
+
+	dir, base := path.Split(name)
+	if len(dir) == 0 || len(base) == 0 {
+		return "", "", false
+	}
+
+	// Never write outside of the destination
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", "", false
+		}
+	}
+
+	return strings.TrimSuffix(dir, "/"), base, true
 }
 
 func (cData CommandData) handleFileEnding(fileName string) string {
-	if cData.Extract && strings.HasSuffix(fileName, ".gz") {
-		fileName = fileName[:len(fileName)-3]
+	if !cData.Extract {
+		return fileName
+	}
+
+	for _, algorithm := range CompressionAlgorithms {
+		if suffix := compressionSuffix(algorithm); strings.HasSuffix(fileName, suffix) {
+			return strings.TrimSuffix(fileName, suffix)
+		}
 	}
 
 	return fileName
@@ -312,7 +529,7 @@ func (cData CommandData) handleFileEnding(fileName string) string {
 func resolveOutputFile(fileName, outputFile string, extract bool) string {
 	localFile := gaw.ResolveFullPath(outputFile)
 
-	// remove .gz suffix if file gets extracted...
+	// remove compression suffix if file gets extracted...
 	fileName = CommandData{Extract: extract}.handleFileEnding(fileName)
 
 	// Replace fileSeparators to prevent writing file to an other directory
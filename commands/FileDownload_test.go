@@ -0,0 +1,31 @@
+package commands
+
+import "testing"
+
+func TestSplitStoredPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		dir, base string
+		ok        bool
+	}{
+		{"file.txt", "", "", false},
+		{"a/b", "a", "b", true},
+		{"a/b/c.txt", "a/b", "c.txt", true},
+		{"a//b", "a", "b", true},
+		{"./a/./b", "a", "b", true},
+		{"/etc/passwd", "etc", "passwd", true},
+		{"../x", "", "", false},
+		{"a/../../x", "", "", false},
+		{"a/../x", "", "", false},
+		{"a/..", "", "", false},
+		{"a/", "", "", false},
+		{"/", "", "", false},
+	}
+
+	for _, test := range tests {
+		dir, base, ok := splitStoredPath(test.name)
+		if dir != test.dir || base != test.base || ok != test.ok {
+			t.Errorf("%s: expected (%q, %q, %v), got (%q, %q, %v)", test.name, test.dir, test.base, test.ok, dir, base, ok)
+		}
+	}
+}
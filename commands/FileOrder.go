@@ -1,8 +1,10 @@
 package commands
 
 import (
+	"fmt"
 	"sort"
 	"strings"
+	"unicode"
 
 	libdm "github.com/DataManager-Go/libdatamanager"
 	"github.com/JojiiOfficial/gaw"
@@ -11,6 +13,12 @@ import (
 //FileOrder order/sort stuff
 type FileOrder int16
 
+//SortKey a single key of a sort expression
+type SortKey struct {
+	Order   FileOrder
+	Reverse bool
+}
+
 //FileSorter a sorter
 type FileSorter struct {
 	Files   []*libdm.FileResponseItem
@@ -32,7 +40,7 @@ func (sorter *FileSorter) Reversed(reversed bool) *FileSorter {
 }
 
 //AvailableOrders options fo ordering
-var AvailableOrders = []string{"id", "name", "size", "pubname", "created", "namespace"}
+var AvailableOrders = []string{"id", "name", "size", "pubname", "created", "namespace", "checksum", "encryption", "public", "tag", "group", "natural"}
 
 //ReversedSuffixes suffixes for reversing sort
 var ReversedSuffixes = []string{"r", "d"}
@@ -46,29 +54,37 @@ const (
 	PubNameOrder
 	CreatedOrder
 	NamespaceOrder
+	ChecksumOrder
+	EncryptionOrder
+	PublicOrder
+	TagOrder
+	GroupOrder
+	NaturalNameOrder
 )
 
+//fileOrders orders by their name in AvailableOrders
+var fileOrders = map[string]FileOrder{
+	"id":         IDOrder,
+	"name":       NameOrder,
+	"size":       SizeOrder,
+	"pubname":    PubNameOrder,
+	"created":    CreatedOrder,
+	"namespace":  NamespaceOrder,
+	"checksum":   ChecksumOrder,
+	"encryption": EncryptionOrder,
+	"public":     PublicOrder,
+	"tag":        TagOrder,
+	"group":      GroupOrder,
+	"natural":    NaturalNameOrder,
+}
+
 //FileOrderFromString return order from string
 func FileOrderFromString(str string) *FileOrder {
 	//remove direction
-	str = strings.Split(str, "/")[0]
-
-	order := NoOrder
-
-	switch str {
-	case AvailableOrders[0]:
-		order = IDOrder
-	case AvailableOrders[1]:
-		order = NameOrder
-	case AvailableOrders[2]:
-		order = SizeOrder
-	case AvailableOrders[3]:
-		order = PubNameOrder
-	case AvailableOrders[4]:
-		order = CreatedOrder
-	case AvailableOrders[5]:
-		order = NamespaceOrder
-	default:
+	str = strings.TrimSpace(strings.Split(str, "/")[0])
+
+	order, ok := fileOrders[str]
+	if !ok {
 		return nil
 	}
 
@@ -81,70 +97,189 @@ func IsOrderReversed(str string) bool {
 		return false
 	}
 
-	direction := strings.Split(str, "/")[1]
+	direction := strings.TrimSpace(strings.Split(str, "/")[1])
 	return gaw.IsInStringArray(direction, ReversedSuffixes)
 }
 
+//ParseSortExpression parses a comma separated list of orders
+//like "namespace,size/r,name" into sortkeys
+func ParseSortExpression(expr string) ([]SortKey, error) {
+	var keys []SortKey
+
+	for _, part := range strings.Split(expr, ",") {
+		if len(strings.TrimSpace(part)) == 0 {
+			continue
+		}
+
+		order := FileOrderFromString(part)
+		if order == nil {
+			return nil, fmt.Errorf("sort by '%s' not supporded", strings.TrimSpace(part))
+		}
+
+		keys = append(keys, SortKey{
+			Order:   *order,
+			Reverse: IsOrderReversed(part),
+		})
+	}
+
+	return keys, nil
+}
+
 //SortBy order files
 func (sorter FileSorter) SortBy(by FileOrder) {
 	if by == NoOrder {
 		return
 	}
 
+	sorter.SortByKeys([]SortKey{{
+		Order:   by,
+		Reverse: sorter.Reverse,
+	}})
+}
+
+//SortByKeys order files by multiple keys. The first key has
+//the highest priority, files equal in all keys are ordered by ID
+func (sorter FileSorter) SortByKeys(keys []SortKey) {
+	if len(keys) == 0 {
+		return
+	}
+
+	sort.SliceStable(sorter.Files, func(i, j int) bool {
+		a, b := sorter.Files[i], sorter.Files[j]
+
+		for _, key := range keys {
+			cmp := compareFiles(key.Order, a, b)
+			if cmp == 0 {
+				continue
+			}
+
+			if key.Reverse {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+
+		// Use ID as stable tiebreaker
+		return a.ID < b.ID
+	})
+}
+
+// Compare two files by the given order. Returns
+// a negative value if a < b, 0 if a == b and a
+// positive value if a > b
+func compareFiles(by FileOrder, a, b *libdm.FileResponseItem) int {
 	switch by {
 	case IDOrder:
-		sort.Slice(sorter.Files, sorter.sortLessID)
+		return compareUint(uint64(a.ID), uint64(b.ID))
 	case NameOrder:
-		sort.Slice(sorter.Files, sorter.sortLessName)
+		return strings.Compare(a.Name, b.Name)
 	case SizeOrder:
-		sort.Slice(sorter.Files, sorter.sortLessSize)
+		return compareInt(a.Size, b.Size)
 	case PubNameOrder:
-		sort.Slice(sorter.Files, sorter.sortLessPubName)
+		return strings.Compare(a.PublicName, b.PublicName)
 	case CreatedOrder:
-		sort.Slice(sorter.Files, sorter.sortLessCreated)
+		return compareInt(a.CreationDate.Unix(), b.CreationDate.Unix())
 	case NamespaceOrder:
-		sort.Slice(sorter.Files, sorter.sortLessNamespace)
+		return strings.Compare(a.Attributes.Namespace, b.Attributes.Namespace)
+	case ChecksumOrder:
+		return strings.Compare(a.Checksum, b.Checksum)
+	case EncryptionOrder:
+		return compareInt(int64(a.Encryption), int64(b.Encryption))
+	case PublicOrder:
+		return compareBool(a.IsPublic, b.IsPublic)
+	case TagOrder:
+		return strings.Compare(joinSorted(a.Attributes.Tags), joinSorted(b.Attributes.Tags))
+	case GroupOrder:
+		return strings.Compare(joinSorted(a.Attributes.Groups), joinSorted(b.Attributes.Groups))
+	case NaturalNameOrder:
+		return compareNatural(a.Name, b.Name)
 	}
+
+	return 0
 }
 
-func (sorter FileSorter) sortLessID(i, j int) bool {
-	if sorter.Reverse {
-		i, j = j, i
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
 	}
-	return sorter.Files[i].ID < sorter.Files[j].ID
+	return 0
 }
 
-func (sorter FileSorter) sortLessName(i, j int) bool {
-	if sorter.Reverse {
-		i, j = j, i
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
 	}
-	return sorter.Files[i].Name < sorter.Files[j].Name
+	return 0
 }
 
-func (sorter FileSorter) sortLessSize(i, j int) bool {
-	if sorter.Reverse {
-		i, j = j, i
+func compareBool(a, b bool) int {
+	if a == b {
+		return 0
 	}
-	return sorter.Files[i].Size < sorter.Files[j].Size
+	if !a {
+		return -1
+	}
+	return 1
 }
 
-func (sorter FileSorter) sortLessPubName(i, j int) bool {
-	if sorter.Reverse {
-		i, j = j, i
-	}
-	return sorter.Files[i].PublicName < sorter.Files[j].PublicName
+// Join a copy of the slice in a sorted
+// order to make it comparable
+func joinSorted(s []string) string {
+	c := make([]string, len(s))
+	copy(c, s)
+	sort.Strings(c)
+	return strings.Join(c, ",")
 }
 
-func (sorter FileSorter) sortLessCreated(i, j int) bool {
-	if sorter.Reverse {
-		i, j = j, i
+// compareNatural compares two strings treating
+// digit sequences as numbers (file2 < file10)
+func compareNatural(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	i, j := 0, 0
+
+	for i < len(ra) && j < len(rb) {
+		if unicode.IsDigit(ra[i]) && unicode.IsDigit(rb[j]) {
+			// Read both numbers
+			si := i
+			for i < len(ra) && unicode.IsDigit(ra[i]) {
+				i++
+			}
+			sj := j
+			for j < len(rb) && unicode.IsDigit(rb[j]) {
+				j++
+			}
+
+			// Compare without leading zeros
+			na := strings.TrimLeft(string(ra[si:i]), "0")
+			nb := strings.TrimLeft(string(rb[sj:j]), "0")
+			if len(na) != len(nb) {
+				return compareInt(int64(len(na)), int64(len(nb)))
+			}
+			if cmp := strings.Compare(na, nb); cmp != 0 {
+				return cmp
+			}
+			continue
+		}
+
+		ca, cb := unicode.ToLower(ra[i]), unicode.ToLower(rb[j])
+		if ca != cb {
+			return compareInt(int64(ca), int64(cb))
+		}
+
+		i++
+		j++
 	}
-	return sorter.Files[i].CreationDate.Unix() < sorter.Files[j].CreationDate.Unix()
-}
 
-func (sorter FileSorter) sortLessNamespace(i, j int) bool {
-	if sorter.Reverse {
-		i, j = j, i
+	// The shorter string comes first
+	if cmp := compareInt(int64(len(ra)-i), int64(len(rb)-j)); cmp != 0 {
+		return cmp
 	}
-	return sorter.Files[i].Attributes.Namespace < sorter.Files[j].Attributes.Namespace
+
+	return strings.Compare(a, b)
 }
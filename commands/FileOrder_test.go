@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"testing"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+)
+
+func TestParseSortExpression(t *testing.T) {
+	keys, err := ParseSortExpression("namespace, size/r,name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []SortKey{{NamespaceOrder, false}, {SizeOrder, true}, {NameOrder, false}}
+	if len(keys) != len(expected) {
+		t.Fatalf("Expected %d keys, got %d", len(expected), len(keys))
+	}
+
+	for i := range expected {
+		if keys[i] != expected[i] {
+			t.Errorf("Key %d: expected %v, got %v", i, expected[i], keys[i])
+		}
+	}
+
+	if _, err := ParseSortExpression("name,foo"); err == nil {
+		t.Error("Expected error for invalid order")
+	}
+}
+
+func TestFileOrderFromString(t *testing.T) {
+	for _, name := range AvailableOrders {
+		if FileOrderFromString(name) == nil {
+			t.Errorf("Order %s can't be parsed", name)
+		}
+	}
+
+	if order := FileOrderFromString("natural/r"); order == nil || *order != NaturalNameOrder {
+		t.Errorf("Expected natural order, got %v", order)
+	}
+
+	if FileOrderFromString("foo") != nil {
+		t.Error("Expected nil for invalid order")
+	}
+}
+
+func TestSortByKeys(t *testing.T) {
+	files := []*libdm.FileResponseItem{
+		{ID: 1, Name: "b", Size: 10, Attributes: libdm.FileAttributes{Namespace: "x"}},
+		{ID: 2, Name: "a", Size: 20, Attributes: libdm.FileAttributes{Namespace: "x"}},
+		{ID: 3, Name: "c", Size: 20, Attributes: libdm.FileAttributes{Namespace: "a"}},
+		{ID: 4, Name: "a", Size: 20, Attributes: libdm.FileAttributes{Namespace: "x"}},
+	}
+
+	keys, _ := ParseSortExpression("namespace,size/r,name")
+	NewFileSorter(files).SortByKeys(keys)
+
+	expected := []uint{3, 2, 4, 1}
+	for i := range expected {
+		if files[i].ID != expected[i] {
+			t.Errorf("Position %d: expected ID %d, got %d", i, expected[i], files[i].ID)
+		}
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{"file2", "file10", true},
+		{"file10", "file2", false},
+		{"img007.png", "img10.png", true},
+		{"a", "b", true},
+		{"abc", "abc1", true},
+	}
+
+	for _, test := range tests {
+		if less := compareNatural(test.a, test.b) < 0; less != test.less {
+			t.Errorf("compareNatural(%s, %s) < 0: expected %t", test.a, test.b, test.less)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package commands
+
+import "os"
+
+// Files on windows have no unix owner
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
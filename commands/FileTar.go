@@ -0,0 +1,318 @@
+package commands
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+)
+
+// Prefix of the PAX records describing a file in a tar stream
+const tarRecordPrefix = "DATAMANAGER."
+
+// DownloadTar writes all files matching name or id as a single
+// tar stream into output. Use "-" to write to stdout
+func (cData *CommandData) DownloadTar(name string, id uint, output string) {
+	if len(name) == 0 && id == 0 && !cData.All {
+		fmtError("Missing a valid parameter. Provide fileID, Filename or use --all")
+		return
+	}
+
+	// Keep stdout clean for the stream and
+	// print everything else to stderr
+	var out io.Writer = os.Stdout
+	if output == "-" {
+		cData.messages = os.Stderr
+	}
+	msg := cData.messageWriter()
+
+	resp, err := cData.listFiles(name, id, false, cData.FileAttributes, 2)
+	if err != nil {
+		fprintError(msg, "listing files", responseErrorCause(err))
+		return
+	}
+
+	// Split files are written as a single file
+	files := collapseSplitFiles(resp.Files)
+
+	if len(files) == 0 {
+		fmt.Fprintln(msg, "No files found")
+		return
+	}
+
+	if len(files) > 1 && !cData.All {
+		fmt.Fprintf(msg, "Found %d files matching '%s'. Use the fileID or --all to download all of them\n", len(files), name)
+		return
+	}
+
+	var outFile *os.File
+	if output != "-" {
+		output = gaw.ResolveFullPath(output)
+		if gaw.FileExists(output) && !cData.Force {
+			fmt.Printf("File '%s' already exists. Use -f to overwrite it or choose a different outputfile\n", output)
+			return
+		}
+
+		outFile, err = os.OpenFile(output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+		if err != nil {
+			printError("creating file", err.Error())
+			return
+		}
+		defer outFile.Close()
+		out = outFile
+	}
+
+	var progressView *ProgressView
+	if !cData.Quiet {
+		progressView = newProgressViewTo(msg)
+	}
+
+	var maxItemLen int
+	for i := range files {
+		if len(files[i].Name) > maxItemLen {
+			maxItemLen = len(files[i].Name)
+		}
+	}
+
+	tw := tar.NewWriter(out)
+	names := make(map[string]bool)
+
+	// A failed file breaks the stream, so stop at the first error
+	for i := range files {
+		var bar *Bar
+		if progressView != nil {
+			// The total gets corrected once the size is known
+			size := files[i].Size
+			if plainSizeKnown(&files[i]) {
+				size = plainFileSize(&files[i])
+			}
+
+			bar = NewBar(DownloadTask, size, files[i].Name, false, maxItemLen)
+			progressView.AddBar(bar)
+		}
+
+		err = cData.writeTarFile(tw, &files[i], names, bar)
+
+		text := fmt.Sprintf("added '%s'", files[i].Name)
+		if err != nil {
+			text = getError("downloading file", err.Error())
+		}
+
+		if bar != nil {
+			bar.stop(text)
+		} else if err != nil {
+			fmt.Fprintln(msg, text)
+		}
+
+		if err != nil {
+			break
+		}
+	}
+
+	if err == nil {
+		err = tw.Close()
+		if err != nil {
+			fprintError(msg, "writing tar", err.Error())
+		}
+	}
+
+	if progressView != nil {
+		progressView.awaitBars()
+	}
+
+	if err != nil {
+		if outFile != nil {
+			outFile.Close()
+			ShredderFile(output, -1)
+		}
+		os.Exit(1)
+	}
+
+	if !cData.Quiet {
+		if output == "-" {
+			fmt.Fprintf(msg, "Wrote %d files\n", len(files))
+		} else {
+			fmt.Printf("Saved %d files into '%s'\n", len(files), output)
+		}
+	}
+}
+
+// Write a single decrypted file into tw
+func (cData *CommandData) writeTarFile(tw *tar.Writer, file *libdm.FileResponseItem, names map[string]bool, bar *Bar) error {
+	var key []byte
+	if file.Encryption > 0 {
+		if key, _ = cData.getFileKey(file.ID); len(key) == 0 {
+			return libdm.ErrFileEncrypted
+		}
+	}
+
+	var resp *libdm.FileDownloadResponse
+	err := cData.retry(func() (err error) {
+		resp, err = cData.LibDM.NewFileRequest(file.ID, "", file.Attributes.Namespace).Do()
+		return err
+	}, cData.printRetry("requesting file"))
+	if err != nil {
+		return err
+	}
+	resp.DownloadRequest.DecryptWith(key)
+
+	// The size has to be known before writing the content
+	name := file.Name
+	size := plainFileSize(file)
+
+	var manifest *splitManifest
+	if isSplitManifest(file.Name) {
+		if manifest, err = cData.readSplitManifest(resp); err != nil {
+			return err
+		}
+		name, size = manifest.Name, manifest.Size
+	} else if !plainSizeKnown(file) {
+		return fmt.Errorf("size of files encrypted with %s can't be determined", resp.Encryption)
+	}
+
+	if bar != nil {
+		bar.total = size
+		bar.bar.SetTotal(size, false)
+	}
+
+	hdr := cData.tarHeader(file, tarMemberName(name, file.ID, names), size)
+	if err = tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if manifest != nil {
+		return cData.writeSplitParts(manifest, tw, cData.downloadProxy(bar))
+	}
+
+	digest, _, err := saveResponse(resp, tw, cData.downloadProxy(bar), false, nil)
+	if err != nil {
+		return err
+	}
+
+	if !resp.VerifyChecksum() {
+		return libdm.ErrChecksumNotMatch
+	}
+
+	return cData.verifyDigest(resp, digest, false)
+}
+
+// Build the tar header of file including its attributes as PAX records
+func (cData *CommandData) tarHeader(file *libdm.FileResponseItem, name string, size int64) *tar.Header {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     size,
+		Mode:     0600,
+		ModTime:  file.CreationDate,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			tarRecordPrefix + "id":        strconv.FormatUint(uint64(file.ID), 10),
+			tarRecordPrefix + "name":      file.Name,
+			tarRecordPrefix + "namespace": file.Attributes.Namespace,
+		},
+	}
+
+	if len(file.Checksum) > 0 {
+		hdr.PAXRecords[tarRecordPrefix+"checksum"] = file.Checksum
+	}
+
+	if len(file.Attributes.Groups) > 0 {
+		hdr.PAXRecords[tarRecordPrefix+"groups"] = strings.Join(file.Attributes.Groups, ",")
+	}
+
+	if len(file.Attributes.Tags) > 0 {
+		hdr.PAXRecords[tarRecordPrefix+"tags"] = strings.Join(file.Attributes.Tags, ",")
+	}
+
+	if file.IsPublic && len(file.PublicName) > 0 {
+		hdr.PAXRecords[tarRecordPrefix+"public"] = file.PublicName
+	}
+
+	// Use the attributes of the uploaded local file if known
+	entry, err := cData.index.get(file.ID)
+	if err != nil {
+		fprintWarning(cData.messageWriter(), "reading index", err.Error())
+		return hdr
+	}
+
+	if entry == nil {
+		return hdr
+	}
+
+	if len(entry.SHA256) > 0 && !entry.Compressed {
+		hdr.PAXRecords[tarRecordPrefix+"sha256"] = entry.SHA256
+	}
+
+	if entry.Mode != 0 && !cData.NoPreserve {
+		hdr.Mode = int64(os.FileMode(entry.Mode).Perm())
+		hdr.ModTime = entry.ModTime
+
+		if entry.HasOwner {
+			hdr.Uid, hdr.Gid = entry.UID, entry.GID
+		}
+	}
+
+	return hdr
+}
+
+// Returns a relative member name for a file, which doesn't collide with
+// other members. Files having a path keep it if it's safe to extract
+func tarMemberName(name string, id uint, names map[string]bool) string {
+	if dir, base, ok := splitStoredPath(name); ok {
+		name = dir + "/" + base
+	} else {
+		name = strings.ReplaceAll(name, "/", "-")
+	}
+
+	if name == "." || name == ".." {
+		name = strconv.FormatUint(uint64(id), 10)
+	}
+
+	// Prefix duplicate names with the file ID
+	if names[name] {
+		name = path.Join(path.Dir(name), fmt.Sprintf("%d-%s", id, path.Base(name)))
+	}
+	names[name] = true
+
+	return name
+}
+
+// Write the parts of a split file in order into w
+func (cData *CommandData) writeSplitParts(manifest *splitManifest, w io.Writer, proxy libdm.ReaderProxy) error {
+	digest := sha256.New()
+	w = io.MultiWriter(w, digest)
+
+	for _, part := range manifest.Parts {
+		resp, err := (&DownloadData{FileID: part.FileID}).doRequest(cData, false)
+		if err != nil {
+			return err
+		}
+
+		partDigest, extracted, err := saveResponse(resp, w, proxy, manifest.Compressed, nil)
+		if err != nil {
+			return err
+		}
+
+		if !resp.VerifyChecksum() {
+			return libdm.ErrChecksumNotMatch
+		}
+
+		if err = cData.verifyDigest(resp, partDigest, extracted); err != nil {
+			return err
+		}
+	}
+
+	if hex.EncodeToString(digest.Sum(nil)) != manifest.SHA256 {
+		return ErrDigestNotMatch
+	}
+
+	return nil
+}
@@ -1,14 +1,15 @@
 package commands
 
 import (
+	"crypto/sha256"
 	"fmt"
+	"hash"
 	"io"
 	"net/url"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/DataManager-Go/libdatamanager"
 	libdm "github.com/DataManager-Go/libdatamanager"
@@ -21,6 +22,7 @@ type UploadData struct {
 	PublicName      string
 	FromStdIn       bool
 	SetClip         bool
+	QR              QROptions
 	Public          bool
 	ReplaceFileID   uint
 	ReplaceSameName bool
@@ -29,14 +31,31 @@ type UploadData struct {
 	TotalFiles      int
 	ProgressView    *ProgressView
 	NoArchiving     bool
-
-	customName      bool
-	uploadAsArchive bool
-	maxItemLen      int
+	KeepPaths       bool
+	ArchiveFormat   string
+	SplitSize       string // Upload files bigger than this in parts
+
+	customName        bool
+	uploadAsArchive   bool
+	maxItemLen        int
+	itemAttributes    map[string]*libdm.FileAttributes // Attributes for specific uris
+	itemNames         map[string]string                // Names for specific uris
+	attributes        *libdm.FileAttributes            // Attributes of the current item
+	namespaceExplicit bool                             // Namespace was chosen by the user
+	encryptionKey     []byte                           // Key generated by an autotag rule
+	keyfile           string                           // Keyfile of encryptionKey
+	report            *transferReport                  // Results of all uploads
+	showReport        bool                             // Print the report instead of single results
+	splitSize         int64                            // Parsed SplitSize
 }
 
 // UploadItems to the server and set's its affiliations
 func (cData *CommandData) UploadItems(uris []string, threads int, uploadData *UploadData) {
+	if uploadData.QR.Enabled() && !uploadData.Public {
+		fmt.Println("QR codes can only be created for public files. Use --public")
+		return
+	}
+
 	// Stdin can only be used
 	// without additional files
 	if uploadData.FromStdIn {
@@ -46,7 +65,7 @@ func (cData *CommandData) UploadItems(uris []string, threads int, uploadData *Up
 
 	// Build new slice containing the
 	// correct file/uri order
-	uris = parseURIArgUploadCommand(uris, uploadData.NoArchiving)
+	uris, uploadData.itemNames = parseURIArgUploadCommand(uris, uploadData.NoArchiving, uploadData.KeepPaths, cData.fileFilter)
 	if uris == nil {
 		return
 	}
@@ -71,6 +90,18 @@ func (cData *CommandData) UploadItems(uris []string, threads int, uploadData *Up
 		return
 	}
 
+	// Parse the size of split parts
+	var err error
+	if uploadData.splitSize, err = parseByteSize(uploadData.SplitSize); err != nil {
+		printError("parsing split size", err.Error())
+		return
+	}
+
+	if uploadData.splitSize > 0 && (uploadData.ReplaceFileID > 0 || uploadData.Public || len(uploadData.PublicName) > 0) {
+		fmt.Println("Split files can't be replaced or published")
+		return
+	}
+
 	// Verify combinations
 	if uploadData.TotalFiles > 1 {
 		if uploadData.SetClip {
@@ -78,87 +109,153 @@ func (cData *CommandData) UploadItems(uris []string, threads int, uploadData *Up
 			return
 		}
 
+		if uploadData.QR.Enabled() {
+			fmt.Println("You can't create QR codes while uploading multiple files")
+			return
+		}
+
 		if len(uploadData.PublicName) > 0 {
 			fmt.Println("You can't upload multiple files with the same public name")
 		}
 	}
 
 	// Upload Files
-	if cData.runUploadPool(uploadData, uris, threads) {
-		uploadData.ProgressView.ProgressContainer.Wait()
-
-		for i := range uploadData.ProgressView.Bars {
-			if i >= len(uploadData.ProgressView.RawBars) {
-				continue
-			}
-
-			for !uploadData.ProgressView.RawBars[i].done {
-				time.Sleep(100 * time.Millisecond)
-			}
-		}
-	}
+	cData.runUploadPool(uploadData, uris, threads)
 }
 
-// Run parallel Uploads
-func (cData *CommandData) runUploadPool(uploadData *UploadData, uris []string, threads int) bool {
+// Run parallel Uploads, print a report
+// and exit with 1 if an upload failed
+func (cData *CommandData) runUploadPool(uploadData *UploadData, uris []string, threads int) {
+	uploadData.report = newTransferReport(cData.FailFast)
+	uploadData.showReport = uploadData.TotalFiles > 1 && !cData.FailFast
+
 	// Set max connections to amouth of threads
 	cData.LibDM.MaxConnectionsPerHost = threads
 
-	// Write all results into resultChan
-	resultChan := make(chan interface{}, uploadData.TotalFiles)
-
-	// Create pool
+	// Results are collected by the report
 	pool := gopool.New(uploadData.TotalFiles, threads, func(wg *sync.WaitGroup, pos, total, workerID int) interface{} {
 		return cData.uploadEntity(*uploadData, uris[pos])
 	})
 
-	// use custom result channel
-	pool.WithResultChan(resultChan)
-
 	// Start pool and wait for it to complete
 	pool.Run().Wait()
+	uploadData.ProgressView.awaitBars()
 
-	for i := 0; i < uploadData.TotalFiles; i++ {
-		b := (<-resultChan).(bool)
-		if !b {
-			close(resultChan)
-			return false
-		}
+	if uploadData.showReport {
+		cData.printTransferReport(uploadData.report)
 	}
 
-	return true
+	if uploadData.report.hasFailed() {
+		os.Exit(1)
+	}
 }
 
 // Upload upload a URI
 func (cData *CommandData) uploadEntity(uploadData UploadData, uri string) (succ bool) {
 	var uploadResponse *libdm.UploadResponse
 
+	// Skip remaining uploads after a failure
+	if uploadData.report.shouldSkip() {
+		uploadData.report.skipped(uri, 0, "previous upload failed")
+		return
+	}
+
+	// Report failed uploads
+	var err error
+	var skipped bool
+	item := uri
+	defer func() {
+		if !succ && !skipped {
+			uploadData.report.failed(item, 0, err)
+		}
+	}()
+
 	// Set name to filename if not set
 	if len(uploadData.Name) == 0 {
 		if uploadData.ReplaceFileID == 0 {
 			_, fileName := filepath.Split(uri)
 			uploadData.Name = fileName
+
+			// Use the relative path if desired
+			if name, ok := uploadData.itemNames[uri]; ok {
+				uploadData.Name = name
+			}
 		}
 	} else {
 		uploadData.customName = true
 	}
 
+	// Use item specific attributes if available
+	if attributes, ok := uploadData.itemAttributes[uri]; ok {
+		uploadData.attributes = attributes
+	}
+
+	// Run pre upload hooks which might replace the uri
+	namespace := cData.FileAttributes.Namespace
+	if uploadData.attributes != nil {
+		namespace = uploadData.attributes.Namespace
+	}
+
+	hookURI, err := cData.runPreUploadHooks(uri, uploadData.Name, namespace)
+	if err != nil {
+		if err == ErrHookVetoed {
+			fmt.Printf("Skipping '%s': %s\n", uploadData.Name, err)
+			uploadData.report.skipped(item, 0, err.Error())
+			skipped = true
+		} else {
+			printError("running pre upload hook", err.Error())
+		}
+		return
+	}
+	uri = hookURI
+
 	// Determine if uri is an http url
 	isURL := isHTTPURL(uri)
 
 	// Get uri info
 	if !isURL && !uploadData.FromStdIn {
-		s, err := os.Stat(uri)
-		if err != nil {
+		var s os.FileInfo
+		if s, err = os.Stat(uri); err != nil {
 			printError(err, "reading file")
 			return
 		}
 
 		uploadData.uploadAsArchive = s.IsDir()
+
+		// Don't publish secrets
+		if uploadData.Public || len(uploadData.PublicName) > 0 {
+			if err = cData.checkPublicUpload(uploadData.Name, uri); err != nil {
+				return
+			}
+		}
+
+		// -----> Split file <-----
+		if uploadData.splitSize > 0 && s.Mode().IsRegular() && s.Size() > uploadData.splitSize {
+			err = cData.uploadSplitFile(&uploadData, uri, item, s)
+			return err == nil
+		}
+	}
+
+	// Don't publish secrets of streams either
+	stdin := io.Reader(os.Stdin)
+	if uploadData.Public || len(uploadData.PublicName) > 0 {
+		if isURL {
+			err = cData.checkPublicURL(uploadData.Name)
+		} else if uploadData.FromStdIn {
+			stdin, err = cData.checkPublicStdin(uploadData.Name)
+		}
+
+		if err != nil {
+			return
+		}
 	}
 
 	// Create uploadRequest
-	uploadRequest := uploadData.toUploadRequest(cData)
+	uploadRequest, err := uploadData.toUploadRequest(cData, uri)
+	if err != nil {
+		printError("generating key", err.Error())
+		return
+	}
 
 	// Create Uploader
 	execUploader := cData.newUploader(&uploadData, uri, uploadRequest, (!cData.Quiet && !uploadData.FromStdIn))
@@ -179,8 +276,8 @@ func (cData *CommandData) uploadEntity(uploadData UploadData, uri string) (succ
 		} else {
 			// -----> File <-----
 			// Open file
-			f, err := os.Open(uri)
-			if err != nil {
+			var f *os.File
+			if f, err = os.Open(uri); err != nil {
 				printError("opening file", err.Error())
 				return
 			}
@@ -191,20 +288,24 @@ func (cData *CommandData) uploadEntity(uploadData UploadData, uri string) (succ
 		}
 	} else {
 		// -----> StdIn <-----
-		uploadResponse = execUploader.uploadFromStdin()
+		uploadResponse = execUploader.uploadFromStdin(stdin)
 	}
 
 	// Return on error
 	if uploadResponse == nil {
+		err = execUploader.err
+		uploadData.deleteKeyfile(cData.Quiet)
 		return
 	}
 
+	uploadData.report.succeeded(item, uploadResponse.FileID, uploadResponse.FileSize)
+
 	// Return result of postUpload
 	return cData.runPostUpload(&uploadData, uploadResponse, execUploader)
 }
 
 // Build UploadRequest from UploadData
-func (uploadData *UploadData) toUploadRequest(cData *CommandData) *libdatamanager.UploadRequest {
+func (uploadData *UploadData) toUploadRequest(cData *CommandData, uri string) (*libdatamanager.UploadRequest, error) {
 	// Make public if public name was specified
 	if len(uploadData.PublicName) > 0 {
 		uploadData.Public = true
@@ -214,17 +315,19 @@ func (uploadData *UploadData) toUploadRequest(cData *CommandData) *libdatamanage
 	if !uploadData.customName {
 		// Handle archiving
 		if uploadData.uploadAsArchive {
-			// Append .tar ending
-			if !strings.HasSuffix(uploadData.Name, ".tar") && !strings.HasSuffix(uploadData.Name, ".tar.gz") {
-				uploadData.Name += ".tar"
-				uploadData.maxItemLen += 4
+			// Append archive ending
+			suffix := archiveSuffix(uploadData.ArchiveFormat)
+			if !strings.HasSuffix(uploadData.Name, suffix) {
+				uploadData.Name += suffix
+				uploadData.maxItemLen += len(suffix)
 			}
 		}
 
-		// Append .gz ending
-		if cData.Compression && !strings.HasSuffix(uploadData.Name, ".gz") {
-			uploadData.Name += ".gz"
-			uploadData.maxItemLen += 3
+		// Append compression ending
+		suffix := compressionSuffix(cData.Compression)
+		if len(suffix) > 0 && !strings.HasSuffix(uploadData.Name, suffix) {
+			uploadData.Name += suffix
+			uploadData.maxItemLen += len(suffix)
 		}
 	}
 
@@ -234,8 +337,23 @@ func (uploadData *UploadData) toUploadRequest(cData *CommandData) *libdatamanage
 		cData.FileAttributes.Namespace = cData.getRealNamespace()
 	}
 
+	attributes := cData.FileAttributes
+	if uploadData.attributes != nil {
+		attributes = *uploadData.attributes
+	}
+
+	// Apply autotag rules. Replacing a file keeps its attributes
+	var autotag *autotagResult
+	if uploadData.ReplaceFileID == 0 {
+		autotag = cData.evalAutotagRules(uploadData.autotagItem(uri))
+		if autotag != nil {
+			// Namespaces chosen by the user (namespace upload or -n) are preferred
+			autotag.apply(&attributes, uploadData.namespaceExplicit || namespaceOverwritten())
+		}
+	}
+
 	// Create upload request
-	uploadRequest := cData.LibDM.NewUploadRequest(uploadData.Name, cData.FileAttributes)
+	uploadRequest := cData.LibDM.NewUploadRequest(uploadData.Name, attributes)
 	uploadRequest.ReplaceFileID = uploadData.ReplaceFileID
 
 	if uploadData.ReplaceSameName {
@@ -250,19 +368,27 @@ func (uploadData *UploadData) toUploadRequest(cData *CommandData) *libdatamanage
 	if len(cData.Encryption) > 0 {
 		encryption := libdm.ChiperToInt(cData.Encryption)
 		uploadRequest.Encrypted(encryption, cData.EncryptionKey)
+	} else if autotag != nil && len(autotag.encryption) > 0 {
+		// Each file encrypted by a rule gets its own key
+		var err error
+		uploadData.encryptionKey, uploadData.keyfile, err = cData.genAutotagKey(autotag.keysize)
+		if err != nil {
+			return nil, err
+		}
+
+		uploadRequest.Encrypted(libdm.ChiperToInt(autotag.encryption), uploadData.encryptionKey)
 	}
 
 	// Publish file
 	if uploadData.Public {
 		uploadRequest.MakePublic(uploadData.PublicName)
-	}
 
-	// Set compression
-	if cData.Compression {
-		uploadRequest.Compress()
+		if uploadRequest.Encryption > 0 {
+			warnEncryptedPublic(uploadData.Name)
+		}
 	}
 
-	return uploadRequest
+	return uploadRequest, nil
 }
 
 // Hit clipboard, keystore and output trigger
@@ -272,24 +398,37 @@ func (cData *CommandData) runPostUpload(uploadData *UploadData, uploadResponse *
 		cData.setClipboard(uploadResponse.PublicFilename)
 	}
 
-	// Add key to keystore
-	if cData.HasKeystoreSupport() && len(cData.Keyfile) > 0 {
-		keystore, _ := cData.GetKeystore()
-		err := keystore.AddKey(uploadResponse.FileID, cData.Keyfile)
-		if err != nil {
-			printError("writing keystore", err.Error())
+	// Create the QR code of the public url
+	var qr string
+	if uploadData.QR.Enabled() && len(uploadResponse.PublicFilename) > 0 {
+		var err error
+		if qr, err = cData.publicURLQR(uploadResponse.PublicFilename, uploadData.QR); err != nil {
+			printError("creating QR code", err.Error())
 		}
 	}
 
+	cData.storeUploadKey(uploadData, uploadResponse)
+	cData.indexUpload(uploadResponse, uploader.digest, len(cData.Compression) > 0, uploader.info)
+	cData.runPostUploadHooks(uploader.uri, uploadResponse)
+
 	// Print output
 	// Print response as json
 	if cData.OutputJSON {
-		fmt.Println(toJSON(uploadResponse))
+		// The report contains all results
+		if uploadData.showReport {
+			return true
+		}
+
+		if len(qr) > 0 {
+			fmt.Println(toJSON(qrUploadResponse{uploadResponse, qr}))
+		} else {
+			fmt.Println(toJSON(uploadResponse))
+		}
 		return true
 	}
 
 	// Render table with informations
-	text := cData.printUploadResponse(uploadResponse, uploadData, (cData.Quiet || uploadData.TotalFiles > 1), uploader.bar)
+	text := cData.printUploadResponse(uploadResponse, uploadData, (cData.Quiet || uploadData.TotalFiles > 1), uploader.bar, qr)
 
 	// On quietMode (no bar is shown)
 	// just print the output
@@ -300,6 +439,34 @@ func (cData *CommandData) runPostUpload(uploadData *UploadData, uploadResponse *
 	return true
 }
 
+// Add the key of an encrypted upload to the keystore
+func (cData *CommandData) storeUploadKey(uploadData *UploadData, uploadResponse *libdatamanager.UploadResponse) {
+	keyfile := cData.Keyfile
+	if len(uploadData.keyfile) > 0 {
+		keyfile = uploadData.keyfile
+	}
+
+	if cData.HasKeystoreSupport() && len(keyfile) > 0 {
+		keystore, _ := cData.GetKeystore()
+		err := keystore.AddKey(uploadResponse.FileID, keyfile)
+		if err != nil {
+			printError("writing keystore", err.Error())
+		}
+	} else if len(uploadData.keyfile) > 0 && !cData.Quiet && !cData.OutputJSON {
+		fmt.Printf("Encrypted %s using the key %s\n", uploadResponse.Filename, uploadData.keyfile)
+	}
+}
+
+// Delete the keyfile generated for this upload
+func (uploadData *UploadData) deleteKeyfile(quiet bool) {
+	if len(uploadData.keyfile) > 0 {
+		ShredderFile(uploadData.keyfile, -1)
+		if !quiet {
+			fmt.Println("Deleting unused key", uploadData.keyfile)
+		}
+	}
+}
+
 // Upload helper
 type uploader struct {
 	cData         *CommandData         // CLI informations
@@ -308,6 +475,18 @@ type uploader struct {
 	uploadData    *UploadData          // Data containing information for the uploaded fil
 	showProgress  bool                 // Use a progressbar
 	bar           *Bar                 // Progressbar generated if desired
+	err           error                // Error of a failed upload
+	digest        hash.Hash            // SHA-256 of the uploaded content
+	compressor    io.ReadCloser        // Compressed content of the current attempt
+	info          os.FileInfo          // Info of the uploaded local file
+}
+
+// Close the compressor of the last attempt
+func (uploader *uploader) closeCompressor() {
+	if uploader.compressor != nil {
+		uploader.compressor.Close()
+		uploader.compressor = nil
+	}
 }
 
 // Hook func
@@ -324,11 +503,13 @@ func (cData *CommandData) newUploader(uploadData *UploadData, uri string, upload
 	}
 }
 
-// Upload the uri
-func (uploader *uploader) upload(uploadFunc uploadFunc) (uploadResponse *libdm.UploadResponse) {
+// Upload the uri. If rewind is set, failed uploads are retried
+// after rewind reset the source
+func (uploader *uploader) upload(uploadFunc uploadFunc, rewind func() error) (uploadResponse *libdm.UploadResponse) {
 	var chsum string
-	var err error
-	done := make(chan string, 1)
+
+	cData := uploader.cData
+	progress := libdm.NoProxyReader
 
 	if uploader.showProgress {
 		name := uploader.uploadData.Name
@@ -337,7 +518,7 @@ func (uploader *uploader) upload(uploadFunc uploadFunc) (uploadResponse *libdm.U
 		uploader.uploadData.ProgressView.AddBar(uploader.bar)
 
 		// Setup proxy
-		uploader.uploadRequest.ProxyReader = func(r io.Reader) io.Reader {
+		progress = func(r io.Reader) io.Reader {
 			return uploader.bar.bar.ProxyReader(r)
 		}
 
@@ -347,53 +528,132 @@ func (uploader *uploader) upload(uploadFunc uploadFunc) (uploadResponse *libdm.U
 		})
 	}
 
-	// Call upload hook in background
-	go func() {
-		c := make(chan string, 1)
-		uploadResponse, err = uploadFunc(c, uploader.uri)
-		done <- <-c
-	}()
+	// Hash the content while uploading, compress it and respect
+	// bandwidth limits. The proxy gets applied again for each attempt
+	uploader.uploadRequest.ProxyReader = func(r io.Reader) io.Reader {
+		uploader.digest = sha256.New()
+		r = progress(io.TeeReader(r, uploader.digest))
 
-	// Delete keyfile if upload was canceled
-	awaitOrInterrupt(done, func(s os.Signal) {
-		if !uploader.cData.Quiet {
-			fmt.Println(s)
+		if len(cData.Compression) > 0 {
+			uploader.compressor = compressReader(r, cData.Compression, cData.CompressionLevel)
+			r = uploader.compressor
 		}
-		uploader.cData.deleteKeyfile()
-		os.Exit(1)
-	}, func(checksum string) {
-		// On file upload done set chsum to received checksum
-		chsum = checksum
-	})
+
+		return cData.limitUpload(r)
+	}
+
+	doUpload := func() (err error) {
+		done := make(chan string, 1)
+
+		// Call upload hook in background
+		go func() {
+			c := make(chan string, 1)
+			uploadResponse, err = uploadFunc(c, uploader.uri)
+			done <- <-c
+		}()
+
+		// Delete keyfile if upload was canceled
+		awaitOrInterrupt(done, func(s os.Signal) {
+			if !uploader.cData.Quiet {
+				fmt.Println(s)
+			}
+			uploader.cData.deleteKeyfile()
+			uploader.uploadData.deleteKeyfile(uploader.cData.Quiet)
+			os.Exit(1)
+		}, func(checksum string) {
+			// On file upload done set chsum to received checksum
+			chsum = checksum
+		})
+
+		return err
+	}
+
+	// A server error might occur after the file was stored. Retrying it would
+	// create a duplicate, unless the upload replaces a file or it's accepted
+	idempotent := uploader.uploadData.ReplaceFileID > 0 || uploader.uploadData.ReplaceSameName ||
+		(uploader.cData.retryPolicy != nil && uploader.cData.retryPolicy.DuplicateUploads)
+
+	var err error
+	if rewind == nil {
+		err = doUpload()
+	} else {
+		var retried bool
+		err = uploader.cData.retry(func() error {
+			if retried {
+				// Stop the compression of the abandoned attempt
+				uploader.closeCompressor()
+
+				if err := rewind(); err != nil {
+					return err
+				}
+			}
+			retried = true
+
+			err := doUpload()
+			if !idempotent && isServerError(err) {
+				return noRetryError{err}
+			}
+
+			return err
+		}, uploader.onRetry)
+
+		if nrErr, ok := err.(noRetryError); ok {
+			err = nrErr.err
+		}
+	}
+	uploader.closeCompressor()
 
 	// Handle upload errors
 	if err != nil || uploadResponse == nil {
 		printResponseError(err, "uploading file")
+		uploader.err = err
 		uploader.bar.stop()
-		return
+		return nil
 	}
 
 	// Verify checksum
 	if !uploader.cData.verifyChecksum(chsum, uploadResponse.Checksum) {
+		uploader.err = libdm.ErrChecksumNotMatch
 		uploader.bar.stop()
-		return
+		return nil
 	}
 
 	return uploadResponse
 }
 
+// Show a retried upload
+func (uploader *uploader) onRetry(attempt, attempts int, err error) {
+	if uploader.bar != nil {
+		uploader.bar.retry(attempt, attempts)
+		return
+	}
+
+	uploader.cData.printRetry("uploading "+uploader.uploadData.Name)(attempt, attempts, err)
+}
+
 // Upload from reader
 func (uploader *uploader) uploadFromReader(r io.Reader, size int64) *libdm.UploadResponse {
+	// Seekable readers can be uploaded again on errors
+	var rewind func() error
+	if seeker, ok := r.(io.Seeker); ok {
+		if offset, err := seeker.Seek(0, io.SeekCurrent); err == nil {
+			rewind = func() error {
+				_, err := seeker.Seek(offset, io.SeekStart)
+				return err
+			}
+		}
+	}
+
 	return uploader.upload(func(done chan string, uri string) (*libdm.UploadResponse, error) {
 		return uploader.uploadRequest.UploadFromReader(r, size, done, nil)
-	})
+	}, rewind)
 }
 
 // Upload from reader
 func (uploader *uploader) uploadURL(u url.URL) *libdm.UploadResponse {
 	return uploader.upload(func(done chan string, uri string) (*libdm.UploadResponse, error) {
 		return uploader.uploadRequest.UploadURL(&u, done, nil)
-	})
+	}, noRewind)
 }
 
 // Upload a file
@@ -403,19 +663,45 @@ func (uploader *uploader) uploadFile(file *os.File) *libdm.UploadResponse {
 	if err != nil {
 		return nil
 	}
+	uploader.info = s
 
 	// Upload from file reader
 	return uploader.uploadFromReader(file, s.Size())
 }
 
 // Upload from stdin
-func (uploader *uploader) uploadFromStdin() *libdm.UploadResponse {
-	return uploader.uploadFromReader(os.Stdin, 0)
+func (uploader *uploader) uploadFromStdin(stdin io.Reader) *libdm.UploadResponse {
+	return uploader.uploadFromReader(stdin, 0)
 }
 
 // Upload archived folder
 func (uploader *uploader) uploadArchivedFolder() *libdm.UploadResponse {
+	filter := uploader.cData.fileFilter
+
+	// Use size of all files in dir as full upload size
+	size, err := filter.dirSize(uploader.uri)
+	if err != nil {
+		printError("reading folder", err.Error())
+		uploader.err = err
+		return nil
+	}
+
+	uploader.uploadRequest.Archive = true
+
+	// The archive gets written again on each attempt
 	return uploader.upload(func(done chan string, uri string) (*libdm.UploadResponse, error) {
-		return uploader.uploadRequest.UploadArchivedFolder(uri, done, nil)
-	})
+		pr, pw := io.Pipe()
+		defer pr.Close()
+
+		go func() {
+			pw.CloseWithError(writeArchive(pw, uri, uploader.uploadData.ArchiveFormat, filter))
+		}()
+
+		return uploader.uploadRequest.UploadFromReader(pr, size, done, nil)
+	}, noRewind)
+}
+
+// Used for sources which are read again on each upload
+func noRewind() error {
+	return nil
 }
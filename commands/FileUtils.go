@@ -6,7 +6,6 @@ import (
 	"path/filepath"
 
 	libdm "github.com/DataManager-Go/libdatamanager"
-	"github.com/JojiiOfficial/gaw"
 	"github.com/fatih/color"
 )
 
@@ -43,8 +42,12 @@ func (cData *CommandData) printChecksumError(resp *libdm.FileDownloadResponse) {
 	fmt.Println(cData.getChecksumError(resp.LocalChecksum, resp.ServerChecksum))
 }
 
-func parseURIArgUploadCommand(uris []string, noCompress bool) []string {
+// Returns the files to upload. If keepPaths is set, files of
+// folders are named by their path relative to the folders parent
+func parseURIArgUploadCommand(uris []string, noCompress, keepPaths bool, filter *fileFilter) ([]string, map[string]string) {
 	var newURIList []string
+	names := make(map[string]string)
+
 	for i := range uris {
 		uriPath, err := filepath.Abs(uris[i])
 		if err != nil {
@@ -67,34 +70,51 @@ func parseURIArgUploadCommand(uris []string, noCompress bool) []string {
 		// Using --no-compress means uploading all
 		// files inside a given folder
 		if s.IsDir() && noCompress {
-			// Get all files in uriPath
-			files, err := gaw.ListDir(uriPath, true)
+			// Get all files in uriPath which aren't ignored
+			err := filter.walk(uriPath, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+
+				if info.IsDir() {
+					return nil
+				}
+
+				newURIList = append(newURIList, file)
+
+				if keepPaths {
+					rel, err := filepath.Rel(filepath.Dir(uriPath), file)
+					if err != nil {
+						return err
+					}
+					names[file] = filepath.ToSlash(rel)
+				}
+				return nil
+			})
+
 			if err != nil {
 				printError("Listing dir", err.Error())
-				return nil
+				return nil, nil
 			}
-
-			newURIList = append(newURIList, files...)
 		} else {
 			newURIList = append(newURIList, uriPath)
 		}
 	}
 
-	return newURIList
+	return newURIList, names
 }
 
 func sortFiles(sOrder string, files []*libdm.FileResponseItem) bool {
 	// Order output
 	if len(sOrder) > 0 {
-		if order := FileOrderFromString(sOrder); order != nil {
-			// Sort
-			NewFileSorter(files).
-				Reversed(IsOrderReversed(sOrder)).
-				SortBy(*order)
-		} else {
-			fmtError(fmt.Sprintf("sort by '%s' not supporded", sOrder))
+		keys, err := ParseSortExpression(sOrder)
+		if err != nil {
+			fmtError(err.Error())
 			return false
 		}
+
+		// Sort
+		NewFileSorter(files).SortByKeys(keys)
 	} else {
 		// By default sort by creation desc
 		NewFileSorter(files).Reversed(true).SortBy(CreatedOrder)
@@ -112,3 +132,13 @@ func fileSliceToRef(inpItems []libdm.FileResponseItem) []*libdm.FileResponseItem
 
 	return respsl
 }
+
+func fileRefToSlice(inpItems []*libdm.FileResponseItem) []libdm.FileResponseItem {
+	respsl := make([]libdm.FileResponseItem, 0, len(inpItems))
+
+	for i := range inpItems {
+		respsl = append(respsl, *inpItems[i])
+	}
+
+	return respsl
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+)
+
+// Hook events
+const (
+	// HookPreUpload runs before a file gets uploaded. A non zero exit
+	// code skips the file. If the hook prints a path, this path gets
+	// uploaded instead of the original file
+	HookPreUpload = "pre_upload"
+	// HookPostUpload runs after a successful upload. The UploadResponse
+	// is passed as json on stdin
+	HookPostUpload = "post_upload"
+	// HookPostDownload runs after a file was downloaded successfully
+	HookPostDownload = "post_download"
+)
+
+// HookEvents all available hook events
+var HookEvents = []string{HookPreUpload, HookPostUpload, HookPostDownload}
+
+// ErrHookVetoed returned if a pre hook exited with a non zero code
+var ErrHookVetoed = errors.New("vetoed by hook")
+
+// Validate configured hooks
+func validateHooks(hooks map[string][]string) error {
+	for event := range hooks {
+		if !gaw.IsInStringArray(event, HookEvents) {
+			return fmt.Errorf("unknown hook event '%s'", event)
+		}
+	}
+
+	return nil
+}
+
+// Return the commands registered for event
+func (cData *CommandData) getHooks(event string) []string {
+	if cData.NoHooks || cData.CLIConfig == nil {
+		return nil
+	}
+
+	return cData.CLIConfig.Hooks[event]
+}
+
+// Run the pre_upload hooks for uri. Returns the uri to
+// upload which might be changed by a hook
+func (cData *CommandData) runPreUploadHooks(uri, name, namespace string) (string, error) {
+	for _, hook := range cData.getHooks(HookPreUpload) {
+		var stdout bytes.Buffer
+
+		cmd := newHookCmd(hook, map[string]string{
+			"DM_FILE":      uri,
+			"DM_NAME":      name,
+			"DM_NAMESPACE": namespace,
+		})
+		cmd.Stdout = &stdout
+
+		if err := cmd.Run(); err != nil {
+			if _, ok := err.(*exec.ExitError); ok {
+				return uri, ErrHookVetoed
+			}
+
+			return uri, err
+		}
+
+		// Use printed path as new file
+		if newURI := strings.TrimSpace(stdout.String()); len(newURI) > 0 && len(uri) > 0 {
+			if !isHTTPURL(newURI) {
+				if _, err := os.Stat(newURI); err != nil {
+					return uri, fmt.Errorf("hook returned invalid path: %s", err)
+				}
+			}
+
+			uri = newURI
+		}
+	}
+
+	return uri, nil
+}
+
+// Run the post_upload hooks for an uploaded file
+func (cData *CommandData) runPostUploadHooks(uri string, resp *libdm.UploadResponse) {
+	hooks := cData.getHooks(HookPostUpload)
+	if len(hooks) == 0 {
+		return
+	}
+
+	env := map[string]string{
+		"DM_FILE":      uri,
+		"DM_FILE_ID":   strconv.FormatUint(uint64(resp.FileID), 10),
+		"DM_NAME":      resp.Filename,
+		"DM_NAMESPACE": resp.Namespace,
+		"DM_CHECKSUM":  resp.Checksum,
+	}
+
+	if len(resp.PublicFilename) > 0 {
+		env["DM_PUBLIC_URL"] = cData.Config.GetPreviewURL(resp.PublicFilename)
+	}
+
+	for _, hook := range hooks {
+		cmd := newHookCmd(hook, env)
+		cmd.Stdin = strings.NewReader(toJSON(resp))
+
+		if err := cmd.Run(); err != nil {
+			printWarning(fmt.Sprintf("running %s hook", HookPostUpload), err.Error())
+		}
+	}
+}
+
+// Run the post_download hooks for a downloaded file
+func (cData *CommandData) runPostDownloadHooks(file, namespace string, resp *libdm.FileDownloadResponse) {
+	for _, hook := range cData.getHooks(HookPostDownload) {
+		cmd := newHookCmd(hook, map[string]string{
+			"DM_FILE":      file,
+			"DM_FILE_ID":   strconv.FormatUint(uint64(resp.FileID), 10),
+			"DM_NAME":      resp.ServerFileName,
+			"DM_NAMESPACE": namespace,
+			"DM_CHECKSUM":  resp.ServerChecksum,
+		})
+
+		if err := cmd.Run(); err != nil {
+			printWarning(fmt.Sprintf("running %s hook", HookPostDownload), err.Error())
+		}
+	}
+}
+
+// Create a command running hook in a shell. Output is written to
+// stderr to keep the output of the CLI (eg. json) clean
+func newHookCmd(hook string, env map[string]string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", hook)
+	} else {
+		cmd = exec.Command("sh", "-c", hook)
+	}
+
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	return cmd
+}
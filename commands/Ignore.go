@@ -0,0 +1,312 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile file in an uploaded directory containing
+// patterns of files to ignore, using the gitignore syntax
+const IgnoreFile = ".dmignore"
+
+// ignorePattern a single gitignore style pattern
+type ignorePattern struct {
+	re      *regexp.Regexp
+	base    string // Directory the pattern was defined in
+	negate  bool
+	dirOnly bool
+}
+
+// ignoreList a list of gitignore style patterns. Later patterns take precedence
+type ignoreList []ignorePattern
+
+// Parse patterns defined in the directory base (slash separated, relative to the root)
+func parseIgnorePatterns(patterns []string, base string) (ignoreList, error) {
+	var list ignoreList
+
+	for _, line := range patterns {
+		pattern, err := parseIgnorePattern(line, base)
+		if err != nil {
+			return nil, err
+		}
+
+		if pattern != nil {
+			list = append(list, *pattern)
+		}
+	}
+
+	return list, nil
+}
+
+// Parse a single line. Returns nil for empty lines and comments
+func parseIgnorePattern(line, base string) (*ignorePattern, error) {
+	line = strings.TrimRight(line, " \t\r")
+	if len(line) == 0 || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	pattern := ignorePattern{base: base}
+
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	if len(line) == 0 {
+		return nil, nil
+	}
+
+	// Patterns containing a slash are relative to base,
+	// others match in any directory below it
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern '%s'", line)
+	}
+
+	pattern.re = re
+	return &pattern, nil
+}
+
+// Convert a gitignore glob into a regular expression
+func globToRegexp(glob string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			// Zero or more directories
+			sb.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			// Everything inside
+			sb.WriteString(".*")
+			i++
+		case c == '*':
+			sb.WriteString("[^/]*")
+		case c == '?':
+			sb.WriteString("[^/]")
+		case c == '\\' && i+1 < len(glob):
+			i++
+			sb.WriteString(regexp.QuoteMeta(string(glob[i])))
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+
+			sb.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return sb.String()
+}
+
+// Returns true if the last pattern matching name (slash
+// separated, relative to the root) isn't negated
+func (list ignoreList) match(name string, isDir bool) bool {
+	var matched bool
+
+	for i := range list {
+		pattern := &list[i]
+
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+
+		rel := name
+		if len(pattern.base) > 0 {
+			if !strings.HasPrefix(name, pattern.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, pattern.base+"/")
+		}
+
+		if pattern.re.MatchString(rel) {
+			matched = !pattern.negate
+		}
+	}
+
+	return matched
+}
+
+// Read the ignore file in dir if it exists
+func readIgnoreFile(dir, base string) (ignoreList, error) {
+	f, err := os.Open(filepath.Join(dir, IgnoreFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	list, err := parseIgnorePatterns(lines, base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", f.Name(), err)
+	}
+
+	return list, nil
+}
+
+// fileFilter decides which files of an uploaded directory are used
+type fileFilter struct {
+	ignore  ignoreList // Patterns of the config
+	exclude ignoreList // --exclude
+	include ignoreList // --include. If set only matching files are used
+}
+
+// InitFileFilter sets up the patterns deciding which
+// files of uploaded directories are used
+func (cData *CommandData) InitFileFilter(exclude, include []string) error {
+	filter := &fileFilter{}
+
+	var err error
+	if cData.CLIConfig != nil {
+		if filter.ignore, err = parseIgnorePatterns(cData.CLIConfig.Ignore, ""); err != nil {
+			return err
+		}
+	}
+
+	if filter.exclude, err = parseIgnorePatterns(exclude, ""); err != nil {
+		return err
+	}
+
+	if filter.include, err = parseIgnorePatterns(include, ""); err != nil {
+		return err
+	}
+
+	cData.fileFilter = filter
+	return nil
+}
+
+// Walk through all files in dir which aren't ignored. Ignored
+// directories are skipped entirely. The ignore files of all
+// visited directories are applied to their content
+func (filter *fileFilter) walk(dir string, fn filepath.WalkFunc) error {
+	if filter == nil {
+		return filepath.Walk(dir, fn)
+	}
+
+	// Copy to keep the patterns of parallel walks apart
+	ignore := append(ignoreList(nil), filter.ignore...)
+
+	return filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return fn(file, info, err)
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." {
+			if filter.ignored(ignore, rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+
+		if info.IsDir() {
+			base := rel
+			if base == "." {
+				base = ""
+			}
+
+			patterns, err := readIgnoreFile(file, base)
+			if err != nil {
+				return err
+			}
+			ignore = append(ignore, patterns...)
+		}
+
+		return fn(file, info, nil)
+	})
+}
+
+// Returns true if the file name should be skipped
+func (filter *fileFilter) ignored(ignore ignoreList, name string, isDir bool) bool {
+	if ignore.match(name, isDir) || filter.exclude.match(name, isDir) {
+		return true
+	}
+
+	// Directories are visited to find included files
+	if len(filter.include) > 0 && !isDir {
+		return !filter.included(name)
+	}
+
+	return false
+}
+
+// Returns true if the file name or one of its parent directories is included
+func (filter *fileFilter) included(name string) bool {
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if filter.include.match(dir, dir != name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the size of all files in dir which aren't ignored
+func (filter *fileFilter) dirSize(dir string) (int64, error) {
+	var size int64
+
+	err := filter.walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+
+	return size, err
+}
@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestIgnoreListMatch(t *testing.T) {
+	list, err := parseIgnorePatterns([]string{
+		"# comment",
+		"*.log",
+		"!keep.log",
+		"build/",
+		"/root.txt",
+		"docs/**/*.tmp",
+		"cache/**",
+		"file[0-9].txt",
+	}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		matches bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"a/keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false},
+		{"root.txt", false, true},
+		{"a/root.txt", false, false},
+		{"docs/x.tmp", false, true},
+		{"docs/a/b/x.tmp", false, true},
+		{"src/docs/x.tmp", false, false},
+		{"cache/a/b", false, true},
+		{"file1.txt", false, true},
+		{"filex.txt", false, false},
+		{"main.go", false, false},
+	}
+
+	for _, test := range tests {
+		if matches := list.match(test.name, test.isDir); matches != test.matches {
+			t.Errorf("%s: expected %t, got %t", test.name, test.matches, matches)
+		}
+	}
+}
+
+func TestFileFilterWalk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dm-ignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		".dmignore":          "node_modules/\n*.o\n",
+		"main.go":            "",
+		"main.o":             "",
+		"node_modules/a.js":  "",
+		"sub/.dmignore":      "secret.txt\n",
+		"sub/secret.txt":     "",
+		"sub/readme.md":      "",
+		"other/secret.txt":   "",
+		"other/notes.md":     "",
+		"other/skip/file.go": "",
+	}
+
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(file), 0750)
+		if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := &fileFilter{}
+	filter.exclude, _ = parseIgnorePatterns([]string{"skip/"}, "")
+	filter.include, _ = parseIgnorePatterns([]string{"*.go", "other/", "sub/"}, "")
+
+	var walked []string
+	err = filter.walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, file)
+			walked = append(walked, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(walked)
+	expected := []string{"main.go", "other/notes.md", "other/secret.txt", "sub/.dmignore", "sub/readme.md"}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("Expected %v, got %v", expected, walked)
+	}
+}
@@ -0,0 +1,264 @@
+package commands
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/fatih/color"
+	"github.com/jinzhu/gorm"
+)
+
+// IndexDBFile the sqlite DB containing local informations about files
+const IndexDBFile = "index.db"
+
+// ErrDigestNotMatch if the SHA-256 digest of a file doesn't match the indexed one
+var ErrDigestNotMatch = errors.New("sha256 digests don't match")
+
+// IndexFile local informations about an uploaded file
+type IndexFile struct {
+	gorm.Model
+	FileID     uint `gorm:"unique_index"`
+	SHA256     string
+	Compressed bool
+
+	// Attributes of the uploaded local file
+	Mode     uint32 // 0 if unknown
+	ModTime  time.Time
+	UID, GID int
+	HasOwner bool
+}
+
+// Set the attributes of the local file described by info
+func (entry *IndexFile) setFileInfo(info os.FileInfo) {
+	entry.Mode = uint32(info.Mode())
+	entry.ModTime = info.ModTime()
+	entry.UID, entry.GID, entry.HasOwner = fileOwner(info)
+}
+
+// fileIndex the local index. Opened on first use
+type fileIndex struct {
+	mx   sync.Mutex
+	path string
+	db   *gorm.DB
+}
+
+// Create a new index stored next to the config file
+func newFileIndex(configFile string) *fileIndex {
+	return &fileIndex{
+		path: filepath.Join(filepath.Dir(configFile), IndexDBFile),
+	}
+}
+
+// Open the index if not already done
+func (index *fileIndex) open() (*gorm.DB, error) {
+	if index == nil {
+		return nil, errors.New("no index available")
+	}
+
+	index.mx.Lock()
+	defer index.mx.Unlock()
+
+	if index.db != nil {
+		return index.db, nil
+	}
+
+	db, err := gorm.Open("sqlite3", index.path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parallel transfers would lock the db otherwise
+	db.DB().SetMaxOpenConns(1)
+
+	if err = db.AutoMigrate(&IndexFile{}).Error; err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	index.db = db
+	return db, nil
+}
+
+// Close the index
+func (index *fileIndex) close() {
+	if index != nil && index.db != nil {
+		index.db.Close()
+	}
+}
+
+// Add or replace the entry of a file
+func (index *fileIndex) put(entry *IndexFile) error {
+	db, err := index.open()
+	if err != nil {
+		return err
+	}
+
+	if err = db.Unscoped().Where("file_id=?", entry.FileID).Delete(&IndexFile{}).Error; err != nil {
+		return err
+	}
+
+	return db.Create(entry).Error
+}
+
+// Get the entry of fileID. Returns nil if the file isn't indexed
+func (index *fileIndex) get(fileID uint) (*IndexFile, error) {
+	db, err := index.open()
+	if err != nil {
+		return nil, err
+	}
+
+	var entry IndexFile
+	err = db.Where("file_id=?", fileID).First(&entry).Error
+	if gorm.IsRecordNotFoundError(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// Copy the file attributes of the entry of from to the entry of to
+func (index *fileIndex) copyFileInfo(from, to uint) error {
+	src, err := index.get(from)
+	if err != nil || src == nil || src.Mode == 0 {
+		return err
+	}
+
+	dst, err := index.get(to)
+	if err != nil || dst == nil {
+		return err
+	}
+
+	dst.Mode, dst.ModTime = src.Mode, src.ModTime
+	dst.UID, dst.GID, dst.HasOwner = src.UID, src.GID, src.HasOwner
+
+	db, err := index.open()
+	if err != nil {
+		return err
+	}
+
+	return db.Save(dst).Error
+}
+
+// Remove the entries of fileIDs
+func (index *fileIndex) remove(fileIDs []uint) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	db, err := index.open()
+	if err != nil {
+		return err
+	}
+
+	return db.Unscoped().Where("file_id IN (?)", fileIDs).Delete(&IndexFile{}).Error
+}
+
+// Index an uploaded file using the digest of its content. info
+// describes the uploaded local file and can be nil
+func (cData *CommandData) indexUpload(resp *libdm.UploadResponse, digest hash.Hash, compressed bool, info os.FileInfo) {
+	if digest == nil {
+		return
+	}
+
+	entry := &IndexFile{
+		FileID:     resp.FileID,
+		SHA256:     hex.EncodeToString(digest.Sum(nil)),
+		Compressed: compressed,
+	}
+
+	if info != nil {
+		entry.setFileInfo(info)
+	}
+
+	if err := cData.index.put(entry); err != nil {
+		printWarning("writing index", err.Error())
+	}
+}
+
+// Returns the indexed digest the content of resp can be compared
+// with. extracted must be true if the content was decompressed.
+// Returns an empty string if there is none
+func (cData *CommandData) indexedDigest(resp *libdm.FileDownloadResponse, extracted bool) string {
+	entry, err := cData.index.get(resp.FileID)
+	if err != nil {
+		printWarning("reading index", err.Error())
+		return ""
+	}
+
+	// Digests are built from the decrypted content as it was uploaded
+	if entry == nil ||
+		entry.Compressed != extracted ||
+		(len(resp.Encryption) > 0 && !resp.DownloadRequest.Decrypt) {
+		return ""
+	}
+
+	return entry.SHA256
+}
+
+// Compare the digest of a downloaded file with the indexed one. Returns
+// ErrDigestNotMatch on mismatch if --verify is set, otherwise prints a warning
+func (cData *CommandData) verifyDigest(resp *libdm.FileDownloadResponse, digest hash.Hash, extracted bool) error {
+	indexed := cData.indexedDigest(resp, extracted)
+	if len(indexed) == 0 {
+		return nil
+	}
+
+	local := hex.EncodeToString(digest.Sum(nil))
+	if local == indexed {
+		return nil
+	}
+
+	if cData.VerifyFile {
+		return ErrDigestNotMatch
+	}
+
+	msg := cData.messageWriter()
+	fmt.Fprintf(msg, "%s sha256 digests don't match!\n", color.YellowString("Warning"))
+	if !cData.Quiet {
+		fmt.Fprintf(msg, "Local:\t%s\n", local)
+		fmt.Fprintf(msg, "Index:\t%s\n", indexed)
+	}
+
+	return nil
+}
+
+// Restore the mode, mtime and owner of the uploaded
+// file on the downloaded file unless --no-preserve is set
+func (cData *CommandData) restoreFileInfo(file string, resp *libdm.FileDownloadResponse) {
+	if cData.NoPreserve {
+		return
+	}
+
+	entry, err := cData.index.get(resp.FileID)
+	if err != nil {
+		printWarning("reading index", err.Error())
+		return
+	}
+
+	if entry == nil || entry.Mode == 0 {
+		return
+	}
+
+	if err = os.Chmod(file, os.FileMode(entry.Mode).Perm()); err == nil {
+		err = os.Chtimes(file, entry.ModTime, entry.ModTime)
+	}
+
+	// Only root can change the owner
+	if err == nil && entry.HasOwner && os.Geteuid() == 0 {
+		err = os.Lchown(file, entry.UID, entry.GID)
+	}
+
+	if err != nil {
+		printWarning("restoring file attributes", err.Error())
+	}
+}
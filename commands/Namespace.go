@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
 	"strconv"
 	"strings"
@@ -13,6 +14,9 @@ import (
 	"github.com/fatih/color"
 )
 
+// Directory for files without a group
+const noGroupDir = "no_group"
+
 //Colorized strings
 var (
 	GreenSuccessfully = color.HiGreenString("Successfully")
@@ -86,11 +90,11 @@ func ListNamespace(cData *CommandData) {
 }
 
 // DownloadNamespace download files from  namespace
-func (cData *CommandData) DownloadNamespace(exGroups, exTags, exFiles []string, parallelism int, outDir string) {
+func (cData *CommandData) DownloadNamespace(exGroups, exTags, exFiles []string, parallelism int, outDir, sOrder string) {
 	ProcesStrSliceParams(&exTags, &exGroups, &exFiles)
 
 	// Get files in namespace from server
-	files, err := cData.LibDM.ListFiles("", 0, false, libdatamanager.FileAttributes{
+	files, err := cData.listFiles("", 0, false, libdatamanager.FileAttributes{
 		Namespace: cData.FileAttributes.Namespace,
 	}, 2)
 
@@ -99,6 +103,9 @@ func (cData *CommandData) DownloadNamespace(exGroups, exTags, exFiles []string,
 		return
 	}
 
+	// Parts of split files are downloaded using their manifest
+	files.Files = collapseSplitFiles(files.Files)
+
 	// Files with are not excluded
 	var toDownloadFiles []libdatamanager.FileResponseItem
 
@@ -127,12 +134,231 @@ a:
 		toDownloadFiles = append(toDownloadFiles, files.Files[i])
 	}
 
+	// Download files in the requested order
+	if len(sOrder) > 0 {
+		refFiles := fileSliceToRef(toDownloadFiles)
+		if !sortFiles(sOrder, refFiles) {
+			return
+		}
+
+		toDownloadFiles = fileRefToSlice(refFiles)
+	}
+
 	cData.downloadFiles(toDownloadFiles, outDir, parallelism, func(file libdatamanager.FileResponseItem) string {
-		name := "no_group"
-		if len(file.Attributes.Groups) > 0 {
-			name = file.Attributes.Groups[0]
+		// Groups of split files aren't used as directory
+		for _, group := range file.Attributes.Groups {
+			if !strings.HasPrefix(group, SplitGroupPrefix) {
+				return group
+			}
+		}
+
+		return noGroupDir
+	})
+}
+
+// namespaceExists returns true if the user has a namespace called name
+func (cData *CommandData) namespaceExists(name string) (bool, error) {
+	namespaces, err := cData.LibDM.GetNamespaces()
+	if err != nil {
+		return false, err
+	}
+
+	for _, namespace := range namespaces.Slice {
+		// Namespaces are prefixed with the username
+		if namespace == name || namespace == cData.Config.User.Username+"_"+name {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// UploadNamespace uploads all files inside dir into namespace. Files in
+// subfolders are assigned to a group named like their first-level folder
+func (cData *CommandData) UploadNamespace(dir, namespace string, groupMappings, tagMappings []string, keepPaths bool, parallelism int) {
+	ProcesStrSliceParams(&groupMappings, &tagMappings)
+
+	groupMap, err := parsePathMappings(groupMappings)
+	if err != nil {
+		printError("parsing group mapping", err.Error())
+		return
+	}
+
+	tagMap, err := parsePathMappings(tagMappings)
+	if err != nil {
+		printError("parsing tag mapping", err.Error())
+		return
+	}
+
+	// Collect files to upload
+	files, err := listFilesRecursive(dir, cData.fileFilter)
+	if err != nil {
+		printError("listing dir", err.Error())
+		return
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No files found")
+		return
+	}
+
+	// Create namespace if required
+	exists, err := cData.namespaceExists(namespace)
+	if err != nil {
+		printResponseError(err, "listing namespaces")
+		return
+	}
+
+	existingChecksums := make(map[string]bool)
+	if exists {
+		// Get checksums of existing files to skip duplicates
+		resp, err := cData.listFiles("", 0, false, libdatamanager.FileAttributes{
+			Namespace: namespace,
+		}, 2)
+		if err != nil {
+			printResponseError(err, "retrieving files")
+			return
+		}
+
+		for i := range resp.Files {
+			existingChecksums[resp.Files[i].Checksum] = true
+		}
+	} else {
+		if _, err = cData.LibDM.CreateNamespace(namespace); err != nil {
+			printResponseError(err, "creating namespace")
+			return
+		}
+	}
+
+	// Checksums of encrypted or compressed files
+	// can't be compared with local files
+	compareChecksums := len(existingChecksums) > 0 && !cData.RequestedEncryptionInput() && len(cData.Compression) == 0
+
+	uploadData := &UploadData{
+		itemAttributes:    make(map[string]*libdatamanager.FileAttributes),
+		itemNames:         make(map[string]string),
+		namespaceExplicit: true,
+	}
+
+	var uris []string
+	var skipped int
+	for _, file := range files {
+		fullPath := filepath.Join(dir, file)
+
+		if compareChecksums && existingChecksums[fileCrc32(fullPath)] {
+			skipped++
+			continue
+		}
+
+		uris = append(uris, fullPath)
+		uploadData.itemAttributes[fullPath] = &libdatamanager.FileAttributes{
+			Namespace: namespace,
+			Tags:      append(append([]string{}, cData.FileAttributes.Tags...), tagMap.resolve(file)...),
+			Groups:    append(append([]string{}, cData.FileAttributes.Groups...), groupMap.resolveGroups(file)...),
+		}
+
+		if keepPaths {
+			uploadData.itemNames[fullPath] = filepath.ToSlash(file)
+		}
+	}
+
+	if skipped > 0 && !cData.Quiet {
+		fmt.Printf("Skipping %d already existing files\n", skipped)
+	}
+
+	if len(uris) == 0 {
+		fmt.Println("Nothing to upload")
+		return
+	}
+
+	uploadData.TotalFiles = len(uris)
+	uploadData.maxItemLen = getLongestItem(files)
+	uploadData.ProgressView = NewProgressView()
+
+	cData.runUploadPool(uploadData, uris, parallelism)
+}
+
+// pathMapping maps files matching a glob to a value
+type pathMapping struct {
+	pattern string
+	value   string
+}
+
+type pathMappings []pathMapping
+
+// parse mappings in the format <glob>=<value>
+func parsePathMappings(mappings []string) (pathMappings, error) {
+	var pm pathMappings
+
+	for _, mapping := range mappings {
+		i := strings.LastIndex(mapping, "=")
+		if i <= 0 || i == len(mapping)-1 {
+			return nil, fmt.Errorf("invalid mapping '%s'. Use <pattern>=<value>", mapping)
 		}
 
-		return name
+		pm = append(pm, pathMapping{
+			pattern: filepath.ToSlash(mapping[:i]),
+			value:   mapping[i+1:],
+		})
+	}
+
+	return pm, nil
+}
+
+// resolve returns all values of mappings matching file
+func (pm pathMappings) resolve(file string) []string {
+	var values []string
+	file = filepath.ToSlash(file)
+
+	for _, mapping := range pm {
+		if globMatch(mapping.pattern, file) && !gaw.IsInStringArray(mapping.value, values) {
+			values = append(values, mapping.value)
+		}
+	}
+
+	return values
+}
+
+// resolveGroups returns the groups for file. If no mapping
+// matches, the first-level folder is used as group
+func (pm pathMappings) resolveGroups(file string) []string {
+	if groups := pm.resolve(file); len(groups) > 0 {
+		return groups
+	}
+
+	parts := strings.Split(filepath.ToSlash(file), "/")
+
+	// Files in root dir and 'no_group' (created
+	// by namespace download) get no group
+	if len(parts) < 2 || parts[0] == noGroupDir {
+		return nil
+	}
+
+	return []string{parts[0]}
+}
+
+// listFilesRecursive returns the paths of all
+// regular files in dir relative to dir, which aren't ignored
+func listFilesRecursive(dir string, filter *fileFilter) ([]string, error) {
+	var files []string
+
+	err := filter.walk(dir, func(file string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, rel)
+		return nil
 	})
+
+	return files, err
 }
@@ -3,6 +3,7 @@ package commands
 import (
 	"fmt"
 	"io"
+	"os"
 	"strings"
 	"sync"
 	"time"
@@ -51,6 +52,9 @@ type Bar struct {
 	done         bool
 
 	outWriter io.Writer
+
+	retryText string
+	retryMx   sync.Mutex
 }
 
 // NewBar create a new bar
@@ -119,6 +123,11 @@ func NewBar(task BarTask, total int64, name string, singleMode bool, maxItemLen
 		mpb.AppendDecorators(
 			decor.OnComplete(decor.Percentage(decor.WCSyncSpace), ""),
 			decor.OnComplete(decor.CountersKiloByte(" [%d / %d]"), ""),
+			decor.OnComplete(decor.Any(func(decor.Statistics) string {
+				bar.retryMx.Lock()
+				defer bar.retryMx.Unlock()
+				return bar.retryText
+			}), ""),
 		),
 	}...)
 
@@ -148,6 +157,15 @@ func (bar *Bar) stop(text ...string) {
 	bar.bar.SetTotal(bar.total, true)
 }
 
+// Show a retry and reset the progress
+func (bar *Bar) retry(attempt, attempts int) {
+	bar.retryMx.Lock()
+	bar.retryText = fmt.Sprintf(" (retry %d/%d)", attempt, attempts)
+	bar.retryMx.Unlock()
+
+	bar.bar.SetCurrent(0)
+}
+
 // ProgressView holds info for progress
 type ProgressView struct {
 	ProgressContainer *mpb.Progress
@@ -172,14 +190,35 @@ func (pv *ProgressView) AddBar(bbar *Bar) *mpb.Bar {
 	return bar
 }
 
+// awaitBars waits for all bars to be rendered completely
+func (pv *ProgressView) awaitBars() {
+	pv.ProgressContainer.Wait()
+
+	for i := range pv.Bars {
+		if i >= len(pv.RawBars) {
+			continue
+		}
+
+		for !pv.RawBars[i].done {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
 // NewProgressView create new progressview
 func NewProgressView() *ProgressView {
+	return newProgressViewTo(os.Stdout)
+}
+
+// Create a progressview rendering into w
+func newProgressViewTo(w io.Writer) *ProgressView {
 	return &ProgressView{
 		Bars: []*mpb.Bar{},
 		ProgressContainer: mpb.New(
 			mpb.WithWaitGroup(&sync.WaitGroup{}),
 			mpb.WithRefreshRate(50*time.Millisecond),
 			mpb.WithWidth(130),
+			mpb.WithOutput(w),
 		),
 	}
 }
@@ -0,0 +1,273 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+	"github.com/fatih/color"
+	humanTime "github.com/sbani/go-humanizer/time"
+	clitable "gopkg.in/benweidig/cli-table.v2"
+)
+
+// AuditFilter selects public files which shouldn't be public (anymore)
+type AuditFilter struct {
+	MaxAge        int      // Days a file may be public
+	SensitiveTags []string // Tags of files which shouldn't be public
+}
+
+// Empty returns true if the filter doesn't select any file
+func (filter AuditFilter) Empty() bool {
+	return filter.MaxAge <= 0 && len(filter.SensitiveTags) == 0
+}
+
+// Returns the reasons why file is selected by the filter
+func (filter AuditFilter) check(file *libdm.FileResponseItem, now time.Time) []string {
+	var reasons []string
+
+	if filter.MaxAge > 0 {
+		if days := int(now.Sub(file.CreationDate).Hours() / 24); days > filter.MaxAge {
+			reasons = append(reasons, fmt.Sprintf("older than %d days (%d)", filter.MaxAge, days))
+		}
+	}
+
+	for _, tag := range filter.SensitiveTags {
+		if fileHasTag(file, tag) {
+			reasons = append(reasons, fmt.Sprintf("sensitive tag '%s'", tag))
+		}
+	}
+
+	return reasons
+}
+
+// publicFile a public file of any namespace
+type publicFile struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Namespace  string    `json:"ns"`
+	PublicName string    `json:"pubName"`
+	URL        string    `json:"url"`
+	Creation   time.Time `json:"creation"`
+	Tags       []string  `json:"tags,omitempty"`
+	Encrypted  bool      `json:"encrypted,omitempty"`
+	Reasons    []string  `json:"reasons,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// List the public files of all namespaces. Files
+// not selected by a non empty filter are skipped
+func (cData *CommandData) listPublicFiles(filter AuditFilter) ([]publicFile, int, error) {
+	resp, err := cData.listFiles("", 0, true, cData.FileAttributes, 2)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	public := make([]publicFile, 0)
+	var total int
+	now := time.Now()
+
+	for i := range resp.Files {
+		file := &resp.Files[i]
+		if !file.IsPublic {
+			continue
+		}
+		total++
+
+		reasons := filter.check(file, now)
+		if !filter.Empty() && len(reasons) == 0 {
+			continue
+		}
+
+		public = append(public, publicFile{
+			ID:         file.ID,
+			Name:       file.Name,
+			Namespace:  file.Attributes.Namespace,
+			PublicName: file.PublicName,
+			URL:        cData.Config.GetPreviewURL(file.PublicName),
+			Creation:   file.CreationDate,
+			Tags:       file.Attributes.Tags,
+			Encrypted:  file.Encryption > 0,
+			Reasons:    reasons,
+		})
+	}
+
+	sort.SliceStable(public, func(i, j int) bool {
+		if public[i].Namespace != public[j].Namespace {
+			return public[i].Namespace < public[j].Namespace
+		}
+		return public[i].ID < public[j].ID
+	})
+
+	return public, total, nil
+}
+
+// ListPublicFiles lists the public files of all namespaces
+func (cData *CommandData) ListPublicFiles() {
+	files, _, err := cData.listPublicFiles(AuditFilter{})
+	if err != nil {
+		printResponseError(err, "listing files")
+		return
+	}
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(files))
+		return
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No public files found")
+		return
+	}
+
+	cData.printPublicFiles(files, false)
+}
+
+// AuditPublicFiles lists public files selected by the filter. Unset
+// values of the filter are taken from the cli config. Exits with 1
+// if a file was found
+func (cData *CommandData) AuditPublicFiles(filter AuditFilter) {
+	ProcesStrSliceParam(&filter.SensitiveTags)
+
+	if cData.CLIConfig != nil {
+		if filter.MaxAge <= 0 {
+			filter.MaxAge = cData.CLIConfig.Audit.MaxAge
+		}
+
+		if len(filter.SensitiveTags) == 0 {
+			filter.SensitiveTags = cData.CLIConfig.Audit.SensitiveTags
+		}
+	}
+
+	if filter.Empty() {
+		fmtError("Nothing to audit. Use --older-than, --sensitive-tag or set 'audit' in your cli config")
+		return
+	}
+
+	files, total, err := cData.listPublicFiles(filter)
+	if err != nil {
+		printResponseError(err, "listing files")
+		os.Exit(1)
+	}
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(map[string]interface{}{
+			"public":  total,
+			"flagged": files,
+		}))
+	} else {
+		if len(files) > 0 {
+			cData.printPublicFiles(files, true)
+			fmt.Println()
+		}
+
+		fmt.Printf("Audited %d public files: %s %d\n", total, color.YellowString("flagged:"), len(files))
+	}
+
+	if len(files) > 0 {
+		os.Exit(1)
+	}
+}
+
+// Make all public files matching name and the filter private
+func (cData *CommandData) unpublishFiles(name string, filter AuditFilter) {
+	files, _, err := cData.listPublicFiles(filter)
+	if err != nil {
+		printResponseError(err, "listing files")
+		os.Exit(1)
+	}
+
+	// '%' matches all files
+	if name = strings.TrimSpace(name); len(name) > 0 && name != "%" {
+		var matching []publicFile
+		for i := range files {
+			if files[i].Name == name {
+				matching = append(matching, files[i])
+			}
+		}
+		files = matching
+	}
+
+	if len(files) == 0 {
+		fmt.Println("No files found")
+		return
+	}
+
+	if !cData.Yes {
+		// Keep json output parseable
+		if !cData.OutputJSON {
+			cData.printPublicFiles(files, true)
+			fmt.Println()
+		}
+
+		if i, _ := gaw.ConfirmInput(fmt.Sprintf("Do you really want to unpublish %d files? (y/n)> ", len(files)), bufio.NewReader(os.Stdin)); !i {
+			return
+		}
+	}
+
+	var failed int
+	for i := range files {
+		_, err := cData.updateFile("", files[i].ID, files[i].Namespace, false, libdm.FileChanges{
+			SetPrivate: true,
+		})
+
+		if err != nil {
+			files[i].Error = err.Error()
+			failed++
+
+			if !cData.OutputJSON {
+				printResponseError(err, fmt.Sprintf("unpublishing '%s'", files[i].Name))
+			}
+		}
+	}
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(files))
+	} else {
+		fmt.Printf("Unpublished %d files %s\n", len(files)-failed, color.HiGreenString("successfully"))
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// Print public files as table, including the
+// reasons why they were selected if required
+func (cData *CommandData) printPublicFiles(files []publicFile, reasons bool) {
+	headingColor := color.New(color.FgHiGreen, color.Underline, color.Bold)
+
+	header := []interface{}{
+		headingColor.Sprint("ID"), headingColor.Sprint("Name"), headingColor.Sprint("Namespace"), headingColor.Sprint("Created"), headingColor.Sprint("URL"),
+	}
+
+	if reasons {
+		header = append(header, headingColor.Sprint("Reasons"))
+	}
+
+	table := clitable.New()
+	table.ColSeparator = " "
+	table.Padding = 4
+	table.AddRow(header...)
+
+	now := time.Now()
+	for _, file := range files {
+		url := file.URL
+		if file.Encrypted {
+			url += color.YellowString(" (encrypted)")
+		}
+
+		row := []interface{}{file.ID, file.Name, file.Namespace, humanTime.Difference(now, file.Creation), url}
+		if reasons {
+			row = append(row, strings.Join(file.Reasons, ", "))
+		}
+
+		table.AddRow(row...)
+	}
+
+	fmt.Println(table.String())
+}
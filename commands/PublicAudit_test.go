@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+)
+
+func TestAuditFilter(t *testing.T) {
+	now := time.Now()
+	filter := AuditFilter{MaxAge: 30, SensitiveTags: []string{"internal"}}
+
+	tests := []struct {
+		age     int
+		tags    []string
+		reasons int
+	}{
+		{0, nil, 0},
+		{30, nil, 0},
+		{31, nil, 1},
+		{0, []string{"release", "internal"}, 1},
+		{90, []string{"internal"}, 2},
+	}
+
+	for _, test := range tests {
+		file := &libdm.FileResponseItem{
+			CreationDate: now.Add(-time.Duration(test.age) * 24 * time.Hour),
+			Attributes:   libdm.FileAttributes{Tags: test.tags},
+		}
+
+		if reasons := filter.check(file, now); len(reasons) != test.reasons {
+			t.Errorf("age %d, tags %v: expected %d reasons, got %v", test.age, test.tags, test.reasons, reasons)
+		}
+	}
+
+	if !(AuditFilter{}).Empty() || filter.Empty() {
+		t.Error("unexpected result of Empty")
+	}
+}
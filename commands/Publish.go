@@ -0,0 +1,184 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/fatih/color"
+	clitable "gopkg.in/benweidig/cli-table.v2"
+)
+
+// States of a file published by publish --all
+const (
+	publishPublished = "published"
+	publishPublic    = "already public"
+	publishRefused   = "refused"
+	publishFailed    = "failed"
+)
+
+// publishResult the result of publishing a single file
+type publishResult struct {
+	ID         uint            `json:"id"`
+	Name       string          `json:"name"`
+	Status     string          `json:"status"`
+	PublicName string          `json:"pubName,omitempty"`
+	URL        string          `json:"url,omitempty"`
+	Encrypted  bool            `json:"encrypted,omitempty"`
+	Findings   []secretFinding `json:"findings,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Warn about publishing an encrypted file
+func warnEncryptedPublic(name string) {
+	printWarning(fmt.Sprintf("publishing '%s'", name), "the file is encrypted. Its public link serves the encrypted content")
+}
+
+// Scan a file before publishing it. Returns false if it shouldn't be published
+func (cData *CommandData) checkPublish(file *libdm.FileResponseItem) bool {
+	if file.Encryption > 0 {
+		warnEncryptedPublic(file.Name)
+		return true
+	}
+
+	findings, err := cData.scanRemoteFile(file)
+	if err != nil {
+		printResponseError(err, "scanning file")
+		return false
+	}
+
+	if len(findings) == 0 {
+		return true
+	}
+
+	printSecretFindings(file.Name, findings)
+	if cData.Force {
+		return true
+	}
+
+	fmt.Printf("Refusing to publish '%s'. Use --force to publish it anyway\n", file.Name)
+	return false
+}
+
+// Publish all files which don't seem to contain secrets and print a report
+func (cData *CommandData) publishFiles(files []libdm.FileResponseItem) {
+	results := make([]publishResult, len(files))
+
+	for i := range files {
+		file := &files[i]
+		result := &results[i]
+		result.ID = file.ID
+		result.Name = file.Name
+		result.Encrypted = file.Encryption > 0
+
+		if file.IsPublic && len(file.PublicName) > 0 {
+			result.Status = publishPublic
+			result.PublicName = file.PublicName
+			result.URL = cData.Config.GetPreviewURL(file.PublicName)
+			continue
+		}
+
+		// Encrypted files can't be scanned
+		if !result.Encrypted {
+			findings, err := cData.scanRemoteFile(file)
+			if err != nil {
+				result.Status = publishFailed
+				result.Error = err.Error()
+				continue
+			}
+
+			result.Findings = findings
+			if len(findings) > 0 && !cData.Force {
+				result.Status = publishRefused
+				continue
+			}
+		}
+
+		resp, err := cData.LibDM.PublishFile("", file.ID, "", false, libdm.FileAttributes{
+			Namespace: file.Attributes.Namespace,
+		})
+		if err != nil || resp == nil {
+			result.Status = publishFailed
+			if err != nil {
+				result.Error = err.Error()
+			}
+			continue
+		}
+
+		result.Status = publishPublished
+		if rs, ok := resp.(libdm.BulkPublishResponse); ok && len(rs.Files) > 0 {
+			result.PublicName = rs.Files[0].PublicFilename
+			result.URL = cData.Config.GetPreviewURL(result.PublicName)
+		}
+	}
+
+	cData.printPublishReport(results)
+
+	for i := range results {
+		if results[i].Status == publishRefused || results[i].Status == publishFailed {
+			os.Exit(1)
+		}
+	}
+}
+
+// Print the results of publish --all
+func (cData *CommandData) printPublishReport(results []publishResult) {
+	if cData.OutputJSON {
+		fmt.Println(toJSON(results))
+		return
+	}
+
+	var published int
+	for i := range results {
+		if results[i].Status == publishPublished {
+			published++
+		}
+	}
+
+	fmt.Printf("Published %d of %d files\n\n", published, len(results))
+
+	headingColor := color.New(color.FgHiGreen, color.Underline, color.Bold)
+
+	table := clitable.New()
+	table.ColSeparator = " "
+	table.Padding = 4
+	table.AddRow(headingColor.Sprint("ID"), headingColor.Sprint("Name"), headingColor.Sprint("Status"), headingColor.Sprint("Details"))
+
+	for _, result := range results {
+		status := result.Status
+		details := result.URL
+
+		switch result.Status {
+		case publishPublished:
+			status = color.HiGreenString(status)
+		case publishRefused:
+			status = color.YellowString(status)
+			details = result.Findings[0].String()
+			if len(result.Findings) > 1 {
+				details += fmt.Sprintf(" (+%d more)", len(result.Findings)-1)
+			}
+		case publishFailed:
+			status = color.HiRedString(status)
+			details = result.Error
+		}
+
+		// Public links of encrypted files are useless
+		if result.Encrypted && result.Status != publishFailed {
+			status += color.YellowString(" (encrypted)")
+		}
+
+		table.AddRow(result.ID, result.Name, status, strings.TrimSpace(details))
+	}
+
+	fmt.Println(table.String())
+
+	if !cData.Force {
+		for i := range results {
+			if results[i].Status == publishRefused {
+				fmt.Println("Files seeming to contain secrets were refused. Use --force to publish them anyway")
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package commands
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/fatih/color"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Pixels per module of QR code PNGs
+const qrModuleSize = 8
+
+// QROptions how to show a public url as QR code
+type QROptions struct {
+	Terminal bool   // Render the code in the terminal
+	File     string // Write the code as PNG into this file
+}
+
+// Enabled returns true if a QR code should be created
+func (opts QROptions) Enabled() bool {
+	return opts.Terminal || len(opts.File) > 0
+}
+
+// qrUploadResponse an upload response containing
+// the QR code of the public url as data URI
+type qrUploadResponse struct {
+	*libdm.UploadResponse
+	QRCode string `json:"qrCode,omitempty"`
+}
+
+// Create the QR code of the public url of a file. Returns the PNG as data
+// URI in JSON mode, otherwise the code rendered for the terminal if required
+func (cData *CommandData) publicURLQR(publicName string, opts QROptions) (string, error) {
+	code, err := qrcode.New(cData.Config.GetPreviewURL(publicName), qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+
+	var png []byte
+	if len(opts.File) > 0 || cData.OutputJSON {
+		if png, err = code.PNG(-qrModuleSize); err != nil {
+			return "", err
+		}
+	}
+
+	if len(opts.File) > 0 {
+		if err = ioutil.WriteFile(opts.File, png, 0644); err != nil {
+			return "", err
+		}
+	}
+
+	if cData.OutputJSON {
+		return "data:image/png;base64," + base64.StdEncoding.EncodeToString(png), nil
+	}
+
+	if !opts.Terminal {
+		return "", nil
+	}
+
+	return renderQR(code.Bitmap()), nil
+}
+
+// Render a QR code using unicode half blocks. Each line contains two
+// rows of modules. Light modules are drawn in the foreground color,
+// which is white, or the default text color if colors are disabled
+func renderQR(bitmap [][]bool) string {
+	style := color.New(color.FgHiWhite, color.BgBlack)
+
+	lines := make([]string, 0, (len(bitmap)+1)/2)
+	for y := 0; y < len(bitmap); y += 2 {
+		var line strings.Builder
+
+		for x := range bitmap[y] {
+			top := !bitmap[y][x]
+			bottom := y+1 == len(bitmap) || !bitmap[y+1][x]
+
+			switch {
+			case top && bottom:
+				line.WriteString("█")
+			case top:
+				line.WriteString("▀")
+			case bottom:
+				line.WriteString("▄")
+			default:
+				line.WriteString(" ")
+			}
+		}
+
+		lines = append(lines, style.Sprint(line.String()))
+	}
+
+	return strings.Join(lines, "\n")
+}
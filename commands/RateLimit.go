@@ -0,0 +1,146 @@
+package commands
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// rateLimiter a token bucket limiting the throughput
+// of all readers sharing it
+type rateLimiter struct {
+	mx     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+// Create a new limiter allowing bytesPerSecond
+func newRateLimiter(bytesPerSecond int64) *rateLimiter {
+	return &rateLimiter{
+		rate: float64(bytesPerSecond),
+		last: time.Now(),
+	}
+}
+
+// Maximum amount of bytes read at once. Smaller
+// chunks result in a smoother throughput
+func (limiter *rateLimiter) chunkSize() int {
+	size := int(limiter.rate / 10)
+	if size < 1024 {
+		return 1024
+	}
+
+	return size
+}
+
+// Take n tokens. Blocks until they are available
+func (limiter *rateLimiter) wait(n int) {
+	limiter.mx.Lock()
+
+	// Refill bucket. Allow bursts of at most one second
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.rate {
+		limiter.tokens = limiter.rate
+	}
+	limiter.last = now
+
+	// Tokens can go negative. Following
+	// readers have to wait for them
+	limiter.tokens -= float64(n)
+	missing := -limiter.tokens
+	limiter.mx.Unlock()
+
+	if missing > 0 {
+		time.Sleep(time.Duration(missing / limiter.rate * float64(time.Second)))
+	}
+}
+
+// limitedReader reader respecting one or more limiters
+type limitedReader struct {
+	r        io.Reader
+	limiters []*rateLimiter
+}
+
+func (reader limitedReader) Read(b []byte) (int, error) {
+	// Read in chunks to prevent bursts
+	for _, limiter := range reader.limiters {
+		if size := limiter.chunkSize(); len(b) > size {
+			b = b[:size]
+		}
+	}
+
+	n, err := reader.r.Read(b)
+	for _, limiter := range reader.limiters {
+		limiter.wait(n)
+	}
+
+	return n, err
+}
+
+// InitRateLimits sets the limits for all transfers. Empty values use
+// the defaults of the cli config. rate limits uploads and downloads
+// together, upload and download only limit the given direction
+func (cData *CommandData) InitRateLimits(rate, upload, download string) error {
+	if cData.CLIConfig != nil {
+		limits := cData.CLIConfig.Limits
+		if len(rate) == 0 {
+			rate = limits.Rate
+		}
+		if len(upload) == 0 {
+			upload = limits.Upload
+		}
+		if len(download) == 0 {
+			download = limits.Download
+		}
+	}
+
+	for _, limit := range []struct {
+		value   string
+		limiter **rateLimiter
+	}{
+		{rate, &cData.rateLimiter},
+		{upload, &cData.uploadLimiter},
+		{download, &cData.downloadLimiter},
+	} {
+		size, err := parseByteSize(limit.value)
+		if err != nil {
+			return err
+		}
+
+		if size > 0 {
+			*limit.limiter = newRateLimiter(size)
+		}
+	}
+
+	return nil
+}
+
+// Wrap r to respect the upload limits
+func (cData *CommandData) limitUpload(r io.Reader) io.Reader {
+	return cData.limitReader(r, cData.uploadLimiter)
+}
+
+// Wrap r to respect the download limits
+func (cData *CommandData) limitDownload(r io.Reader) io.Reader {
+	return cData.limitReader(r, cData.downloadLimiter)
+}
+
+func (cData *CommandData) limitReader(r io.Reader, limiter *rateLimiter) io.Reader {
+	var limiters []*rateLimiter
+	for _, l := range []*rateLimiter{cData.rateLimiter, limiter} {
+		if l != nil {
+			limiters = append(limiters, l)
+		}
+	}
+
+	if len(limiters) == 0 {
+		return r
+	}
+
+	return limitedReader{
+		r:        r,
+		limiters: limiters,
+	}
+}
@@ -0,0 +1,204 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+)
+
+// RetryPolicy defines how failed requests are retried
+type RetryPolicy struct {
+	Attempts    int    // Max attempts including the first one. Default 3
+	Backoff     string // Delay before the first retry. Doubled for each retry. Default 1s
+	MaxBackoff  string // Max delay between two attempts. Default 30s
+	StatusCodes []int  // HTTP status codes to retry. Default 429, 500, 502, 503, 504
+
+	// Retry new uploads on 5xx status codes as well,
+	// which might store the file more than once
+	DuplicateUploads bool
+
+	backoff, maxBackoff time.Duration
+}
+
+// Parse and validate the policy, applying defaults for empty values
+func (policy *RetryPolicy) init() error {
+	if policy.Attempts <= 0 {
+		policy.Attempts = 3
+	}
+
+	if len(policy.StatusCodes) == 0 {
+		policy.StatusCodes = []int{429, 500, 502, 503, 504}
+	}
+
+	var err error
+	if policy.backoff, err = parseDuration(policy.Backoff, time.Second); err != nil {
+		return err
+	}
+
+	if policy.maxBackoff, err = parseDuration(policy.MaxBackoff, 30*time.Second); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// InitRetryPolicy sets the policy used for retrying requests. The policy is
+// taken from the cli config. If retries is >= 0 it overwrites the attempts
+func (cData *CommandData) InitRetryPolicy(retries int) error {
+	policy := RetryPolicy{}
+	if cData.CLIConfig != nil {
+		policy = cData.CLIConfig.Retry
+	}
+
+	if err := policy.init(); err != nil {
+		return err
+	}
+
+	if retries >= 0 {
+		policy.Attempts = retries + 1
+	}
+
+	cData.retryPolicy = &policy
+	return nil
+}
+
+// Returns the delay before the given retry using
+// exponential backoff with jitter
+func (policy *RetryPolicy) delay(retry int) time.Duration {
+	d := policy.backoff
+	for i := 1; i < retry && d < policy.maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > policy.maxBackoff {
+		d = policy.maxBackoff
+	}
+
+	// Use a random delay between d/2 and d
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+
+	return d
+}
+
+// noRetryError an error which must not be retried
+type noRetryError struct {
+	err error
+}
+
+func (nrErr noRetryError) Error() string {
+	return nrErr.err.Error()
+}
+
+// Returns true if err is a response with a 5xx status code
+func isServerError(err error) bool {
+	var respErr *libdm.ResponseErr
+	return errors.As(err, &respErr) && respErr.Response != nil && respErr.Response.HTTPCode >= 500
+}
+
+// Return true if err is worth retrying
+func (policy *RetryPolicy) isRetryable(err error) bool {
+	if err == nil || err == libdm.ErrChecksumNotMatch || err == libdm.ErrFileEncrypted {
+		return false
+	}
+
+	if _, ok := err.(noRetryError); ok {
+		return false
+	}
+
+	var respErr *libdm.ResponseErr
+	if errors.As(err, &respErr) {
+		if respErr.Response != nil {
+			for _, code := range policy.StatusCodes {
+				if respErr.Response.HTTPCode == code {
+					return true
+				}
+			}
+			return false
+		}
+
+		if respErr.Err == nil {
+			return false
+		}
+		err = respErr.Err
+	}
+
+	// Proxies return html error pages which can't be parsed
+	var syntaxErr *json.SyntaxError
+	var netErr net.Error
+
+	return errors.As(err, &netErr) ||
+		errors.As(err, &syntaxErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// Run fn until it succeeds, returns a non retryable error or the
+// attempts are exceeded. onRetry gets called before each retry
+func (cData *CommandData) retry(fn func() error, onRetry func(attempt, attempts int, err error)) error {
+	policy := cData.retryPolicy
+	if policy == nil {
+		return fn()
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		if err = fn(); err == nil || attempt >= policy.Attempts || !policy.isRetryable(err) {
+			return err
+		}
+
+		if onRetry != nil {
+			onRetry(attempt+1, policy.Attempts, err)
+		}
+
+		time.Sleep(policy.delay(attempt))
+	}
+}
+
+// Print a message about a retried request
+func (cData *CommandData) printRetry(action string) func(attempt, attempts int, err error) {
+	return func(attempt, attempts int, err error) {
+		if !cData.Quiet {
+			fprintWarning(cData.messageWriter(), fmt.Sprintf("%s failed, retrying (%d/%d)", action, attempt, attempts), err.Error())
+		}
+	}
+}
+
+// List files retrying on transient errors
+func (cData *CommandData) listFiles(name string, id uint, allNamespaces bool, attributes libdm.FileAttributes, verbose uint8) (resp *libdm.FileListResponse, err error) {
+	err = cData.retry(func() error {
+		resp, err = cData.LibDM.ListFiles(name, id, allNamespaces, attributes, verbose)
+		return err
+	}, cData.printRetry("listing files"))
+
+	return
+}
+
+// Update files retrying on transient errors
+func (cData *CommandData) updateFile(name string, id uint, namespace string, all bool, changes libdm.FileChanges) (resp *libdm.IDsResponse, err error) {
+	err = cData.retry(func() error {
+		resp, err = cData.LibDM.UpdateFile(name, id, namespace, all, changes)
+		return err
+	}, cData.printRetry("updating file"))
+
+	return
+}
+
+// Parse a duration using def if s is empty
+func parseDuration(s string, def time.Duration) (time.Duration, error) {
+	if len(s) == 0 {
+		return def, nil
+	}
+
+	return time.ParseDuration(s)
+}
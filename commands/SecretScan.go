@@ -0,0 +1,358 @@
+package commands
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/fatih/color"
+)
+
+// ErrSecretsFound if a file to publish seems to contain secrets
+var ErrSecretsFound = errors.New("file seems to contain secrets")
+
+// ErrSecretsUnchecked if the content of a file to publish can't be scanned
+var ErrSecretsUnchecked = errors.New("content can't be scanned for secrets")
+
+var errScanLimit = errors.New("scan limit reached")
+
+// Only the beginning of large files is scanned
+const secretScanLimit = 10 * 1024 * 1024
+
+// Minimum shannon entropy of a random looking string
+const secretMinEntropy = 4.5
+
+// secretRule matches a common secret format
+type secretRule struct {
+	name string
+	re   *regexp.Regexp
+
+	// Optional check of the submatches
+	valid func(match []string) bool
+}
+
+var secretRules = []secretRule{
+	{"private key", regexp.MustCompile(`-----BEGIN[ A-Z0-9]*PRIVATE KEY( BLOCK)?-----`), nil},
+	{"AWS access key", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`), nil},
+	{"AWS secret key", regexp.MustCompile(`(?i)aws_?secret_?(?:access_?)?key["']?\s*[:=]\s*["']?[A-Za-z0-9/+]{40}`), nil},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{40,})`), nil},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`), nil},
+	{"Google API key", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`), nil},
+	{"Stripe key", regexp.MustCompile(`\b[rs]k_live_[0-9A-Za-z]{20,}`), nil},
+	{"JSON web token", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`), nil},
+	{"secret assignment", regexp.MustCompile(`(?i)\b[a-z0-9_]*(?:password|passwd|secret|token|api_?key)[a-z0-9_]*["']?\s*(?::=|[:=])\s*(?:"([^"\s$<{(\[]{8,})"|'([^'\s$<{(\[]{8,})'|([^\s"'$<{(\[;,]{8,})(?:[\s;,]|$))`), isSecretLiteral},
+}
+
+// Unquoted values referencing variables or fields
+var identifierValue = regexp.MustCompile(`^[A-Za-z_]+(?:\.[A-Za-z_]+)*$`)
+
+// Names of files usually containing secrets
+var secretFileName = regexp.MustCompile(`(?i)(?:^|/)(?:\.env(?:\.[^/]+)?|id_(?:rsa|dsa|ecdsa|ed25519)|\.netrc|\.pgpass|credentials|[^/]+\.(?:pem|key|p12|pfx|jks|keystore))$`)
+
+// Candidates for random looking strings like keys or tokens
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{32,}`)
+
+// secretFinding a possible secret in a file
+type secretFinding struct {
+	File  string `json:"file,omitempty"`
+	Rule  string `json:"rule"`
+	Line  int    `json:"line,omitempty"`
+	Match string `json:"match,omitempty"`
+}
+
+func (finding secretFinding) String() string {
+	s := finding.Rule
+	if len(finding.File) > 0 {
+		s = finding.File + ": " + s
+	}
+
+	if finding.Line > 0 {
+		s += fmt.Sprintf(" in line %d", finding.Line)
+	}
+
+	if len(finding.Match) > 0 {
+		s += fmt.Sprintf(" (%s)", finding.Match)
+	}
+
+	return s
+}
+
+// Scan the content of the file name for secrets
+func scanSecrets(name string, content []byte) []secretFinding {
+	var findings []secretFinding
+
+	if isSecretFileName(name) {
+		findings = append(findings, secretFinding{Rule: "sensitive file name"})
+	}
+
+	// Binary files would produce random hits
+	if bytes.IndexByte(content[:minInt(len(content), 8000)], 0) >= 0 {
+		return findings
+	}
+
+	for i, line := range strings.Split(string(content), "\n") {
+		var matched bool
+
+		for _, rule := range secretRules {
+			if match := rule.find(line); len(match) > 0 {
+				findings = append(findings, secretFinding{Rule: rule.name, Line: i + 1, Match: redactSecret(match)})
+				matched = true
+			}
+		}
+
+		if matched {
+			continue
+		}
+
+		for _, loc := range entropyCandidate.FindAllStringIndex(line, -1) {
+			candidate := line[loc[0]:loc[1]]
+			if isRandomString(candidate) && !isDigest(line[:loc[0]], candidate) {
+				findings = append(findings, secretFinding{Rule: "high entropy string", Line: i + 1, Match: redactSecret(candidate)})
+				break
+			}
+		}
+	}
+
+	return findings
+}
+
+// Returns the first valid match of rule in line
+func (rule secretRule) find(line string) string {
+	if rule.valid == nil {
+		return rule.re.FindString(line)
+	}
+
+	for _, match := range rule.re.FindAllStringSubmatch(line, -1) {
+		if rule.valid(match) {
+			return strings.TrimRight(match[0], " \t;,")
+		}
+	}
+
+	return ""
+}
+
+// Quoted values are literals, unquoted
+// ones may be identifiers in code
+func isSecretLiteral(match []string) bool {
+	bare := match[len(match)-1]
+	return len(bare) == 0 || !identifierValue.MatchString(bare)
+}
+
+func isSecretFileName(name string) bool {
+	name = filepath.ToSlash(name)
+
+	// Templates of env files are meant to be shared
+	for _, suffix := range []string{".example", ".sample", ".template", ".dist"} {
+		if strings.HasSuffix(name, suffix) {
+			return false
+		}
+	}
+
+	return secretFileName.MatchString(name)
+}
+
+// Prefixes of checksums like in go.sum or package-lock.json
+var digestPrefixes = []string{"h1:", "sha1-", "sha256-", "sha384-", "sha512-"}
+
+// Returns true if the random looking candidate is a checksum
+func isDigest(before, candidate string) bool {
+	for _, prefix := range digestPrefixes {
+		if strings.HasSuffix(before, prefix) || strings.HasPrefix(candidate, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns true if s has a high entropy and contains
+// upper and lower case letters and digits
+func isRandomString(s string) bool {
+	var upper, lower, digit bool
+	for _, c := range s {
+		upper = upper || unicode.IsUpper(c)
+		lower = lower || unicode.IsLower(c)
+		digit = digit || unicode.IsDigit(c)
+	}
+
+	return upper && lower && digit && shannonEntropy(s) >= secretMinEntropy
+}
+
+// Bits of entropy per character of s
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+
+	var entropy float64
+	length := float64(len(s))
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+
+	return entropy
+}
+
+// Show only the beginning of a secret
+func redactSecret(s string) string {
+	if len(s) <= 8 {
+		return strings.Repeat("*", len(s))
+	}
+
+	return s[:4] + strings.Repeat("*", 8)
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Scan a local file or all files of a directory for secrets
+func scanLocalPath(uri string, filter *fileFilter) ([]secretFinding, error) {
+	var findings []secretFinding
+
+	err := filter.walk(uri, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		content, err := ioutil.ReadAll(io.LimitReader(f, secretScanLimit))
+		if err != nil {
+			return err
+		}
+
+		name := file
+		if rel, err := filepath.Rel(filepath.Dir(uri), file); err == nil {
+			name = rel
+		}
+
+		for _, finding := range scanSecrets(name, content) {
+			finding.File = filepath.ToSlash(name)
+			findings = append(findings, finding)
+		}
+
+		return nil
+	})
+
+	return findings, err
+}
+
+// scanBuffer collects up to secretScanLimit bytes
+type scanBuffer struct {
+	bytes.Buffer
+}
+
+func (sb *scanBuffer) Write(b []byte) (int, error) {
+	if n := secretScanLimit - sb.Len(); len(b) > n {
+		sb.Buffer.Write(b[:n])
+		return n, errScanLimit
+	}
+
+	return sb.Buffer.Write(b)
+}
+
+// Download the beginning of an uploaded file and scan it for secrets
+func (cData *CommandData) scanRemoteFile(file *libdm.FileResponseItem) ([]secretFinding, error) {
+	var resp *libdm.FileDownloadResponse
+	err := cData.retry(func() (err error) {
+		resp, err = cData.LibDM.NewFileRequest(file.ID, "", file.Attributes.Namespace).Do()
+		return err
+	}, cData.printRetry("requesting file"))
+	if err != nil {
+		return nil, err
+	}
+	resp.DownloadRequest.DecryptWith(nil)
+
+	var buff scanBuffer
+	_, _, err = saveResponse(resp, &buff, cData.limitDownload, true, nil)
+	if err != nil && !errors.Is(err, errScanLimit) {
+		return nil, err
+	}
+
+	return scanSecrets(file.Name, buff.Bytes()), nil
+}
+
+// Print the findings of a file to publish
+func printSecretFindings(name string, findings []secretFinding) {
+	fmt.Printf("%s '%s' seems to contain secrets:\n", color.YellowString("Warning"), name)
+	for _, finding := range findings {
+		fmt.Println("  -", finding)
+	}
+}
+
+// Scan a local file before uploading it as public file. Returns
+// ErrSecretsFound if secrets were found and --force wasn't passed
+func (cData *CommandData) checkPublicUpload(name, uri string) error {
+	findings, err := scanLocalPath(uri, cData.fileFilter)
+	if err != nil {
+		printError("scanning file", err.Error())
+		return err
+	}
+
+	return cData.refuseSecrets(name, findings)
+}
+
+// Scan the beginning of stdin before uploading it as public file.
+// Returns a reader yielding the whole input if it can be published
+func (cData *CommandData) checkPublicStdin(name string) (io.Reader, error) {
+	head, err := ioutil.ReadAll(io.LimitReader(os.Stdin, secretScanLimit))
+	if err != nil {
+		printError("reading stdin", err.Error())
+		return nil, err
+	}
+
+	if err = cData.refuseSecrets(name, scanSecrets(name, head)); err != nil {
+		return nil, err
+	}
+
+	return io.MultiReader(bytes.NewReader(head), os.Stdin), nil
+}
+
+// Content of urls is fetched by the server and can't be scanned
+func (cData *CommandData) checkPublicURL(name string) error {
+	if cData.Force {
+		return nil
+	}
+
+	fmt.Printf("Refusing to publish '%s' since urls can't be scanned for secrets. Use --force to publish it anyway\n", name)
+	return ErrSecretsUnchecked
+}
+
+// Print the findings and return ErrSecretsFound
+// if there are some and --force wasn't passed
+func (cData *CommandData) refuseSecrets(name string, findings []secretFinding) error {
+	if len(findings) == 0 {
+		return nil
+	}
+
+	printSecretFindings(name, findings)
+	if cData.Force {
+		return nil
+	}
+
+	fmt.Printf("Refusing to publish '%s'. Use --force to publish it anyway\n", name)
+	return ErrSecretsFound
+}
@@ -0,0 +1,518 @@
+package commands
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	libdm "github.com/DataManager-Go/libdatamanager"
+	"github.com/JojiiOfficial/gaw"
+	"github.com/JojiiOfficial/gopool"
+)
+
+const (
+	// SplitGroupPrefix prefix of the group linking the parts of a split file
+	SplitGroupPrefix = "dmsplit-"
+
+	// SplitManifestSuffix suffix of the file describing a split file
+	SplitManifestSuffix = ".dmsplit"
+)
+
+// ErrInvalidManifest if a split manifest doesn't describe a complete file
+var ErrInvalidManifest = errors.New("invalid split manifest")
+
+// splitManifest describes a file uploaded in parts
+type splitManifest struct {
+	Name       string      `json:"name"`
+	Size       int64       `json:"size"`
+	SHA256     string      `json:"sha256"`
+	Compressed bool        `json:"compressed,omitempty"`
+	Parts      []splitPart `json:"parts"`
+}
+
+// splitPart a single uploaded part of a split file
+type splitPart struct {
+	FileID uint   `json:"id"`
+	Name   string `json:"name"`
+	Offset int64  `json:"offset"`
+	Size   int64  `json:"size"`
+}
+
+// Returns an error if the parts don't cover the file exactly once
+func (manifest *splitManifest) validate() error {
+	var offset int64
+	for _, part := range manifest.Parts {
+		if part.Offset != offset || part.Size <= 0 {
+			return ErrInvalidManifest
+		}
+		offset += part.Size
+	}
+
+	if offset != manifest.Size || len(manifest.SHA256) == 0 {
+		return ErrInvalidManifest
+	}
+
+	return nil
+}
+
+func isSplitManifest(name string) bool {
+	return strings.HasSuffix(name, SplitManifestSuffix)
+}
+
+// Returns the group linking the parts of a split file or an empty string
+func splitGroup(file *libdm.FileResponseItem) string {
+	for _, group := range file.Attributes.Groups {
+		if strings.HasPrefix(group, SplitGroupPrefix) {
+			return group
+		}
+	}
+
+	return ""
+}
+
+// Upload file in parts of uploadData.splitSize followed by a manifest
+// describing how to put them together. The parts are linked by a new group
+func (cData *CommandData) uploadSplitFile(uploadData *UploadData, uri, item string, info os.FileInfo) error {
+	f, err := os.Open(uri)
+	if err != nil {
+		printError("opening file", err.Error())
+		return err
+	}
+	defer f.Close()
+
+	// Hash the whole file to verify the joined parts
+	digest := sha256.New()
+	if _, err = io.Copy(digest, f); err != nil {
+		printError("reading file", err.Error())
+		return err
+	}
+
+	manifest := splitManifest{
+		Name:       uploadData.Name,
+		Size:       info.Size(),
+		SHA256:     hex.EncodeToString(digest.Sum(nil)),
+		Compressed: len(cData.Compression) > 0,
+	}
+
+	attributes := cData.FileAttributes
+	if uploadData.attributes != nil {
+		attributes = *uploadData.attributes
+	}
+	attributes.Groups = append(append([]string{}, attributes.Groups...), SplitGroupPrefix+gaw.RandString(10))
+
+	count := int((info.Size() + uploadData.splitSize - 1) / uploadData.splitSize)
+
+	type uploadedPart struct {
+		uploadData *UploadData
+		resp       *libdm.UploadResponse
+		uploader   *uploader
+	}
+
+	var parts []uploadedPart
+
+	// Delete already uploaded parts if a part fails
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		for _, part := range parts {
+			part.uploadData.deleteKeyfile(cData.Quiet)
+			if _, derr := cData.LibDM.DeleteFile("", part.resp.FileID, false, libdm.FileAttributes{Namespace: part.resp.Namespace}); derr != nil {
+				printResponseError(derr, "deleting part "+part.resp.Filename)
+			}
+		}
+	}()
+
+	for i := 0; i < count; i++ {
+		offset := int64(i) * uploadData.splitSize
+		size := uploadData.splitSize
+		if offset+size > info.Size() {
+			size = info.Size() - offset
+		}
+
+		partData := *uploadData
+		partData.Name = fmt.Sprintf("%s.part%03d", uploadData.Name, i+1)
+		partData.customName = true
+		partData.attributes = &attributes
+		partData.maxItemLen = uploadData.maxItemLen + len(partData.Name) - len(uploadData.Name)
+		partData.TotalFiles = uploadData.TotalFiles + count
+
+		var part uploadedPart
+		part.uploadData = &partData
+		part.resp, part.uploader, err = cData.uploadSplitPart(&partData, uri, io.NewSectionReader(f, offset, size), size)
+		if err != nil {
+			return err
+		}
+		parts = append(parts, part)
+
+		manifest.Parts = append(manifest.Parts, splitPart{
+			FileID: part.resp.FileID,
+			Name:   part.resp.Filename,
+			Offset: offset,
+			Size:   size,
+		})
+
+		if part.uploader.bar != nil {
+			cData.printUploadResponse(part.resp, &partData, true, part.uploader.bar, "")
+		}
+	}
+
+	// Upload the manifest
+	b, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	manifestData := *uploadData
+	manifestData.Name = uploadData.Name + SplitManifestSuffix
+	manifestData.customName = true
+	manifestData.attributes = &attributes
+	manifestData.maxItemLen = uploadData.maxItemLen + len(SplitManifestSuffix)
+
+	resp, manifestUploader, err := cData.uploadSplitPart(&manifestData, uri, bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return err
+	}
+
+	// Parts are only indexed once the file is complete
+	compressed := len(cData.Compression) > 0
+	for _, part := range parts {
+		cData.storeUploadKey(part.uploadData, part.resp)
+		cData.indexUpload(part.resp, part.uploader.digest, compressed, nil)
+	}
+
+	uploadData.report.succeeded(item, resp.FileID, info.Size())
+
+	// The manifest holds the attributes of the whole file
+	manifestUploader.info = info
+	cData.runPostUpload(&manifestData, resp, manifestUploader)
+	return nil
+}
+
+// Upload r as a single file of a split upload
+func (cData *CommandData) uploadSplitPart(uploadData *UploadData, uri string, r io.Reader, size int64) (*libdm.UploadResponse, *uploader, error) {
+	uploadRequest, err := uploadData.toUploadRequest(cData, uri)
+	if err != nil {
+		printError("generating key", err.Error())
+		return nil, nil, err
+	}
+
+	execUploader := cData.newUploader(uploadData, uri, uploadRequest, !cData.Quiet)
+
+	resp := execUploader.uploadFromReader(r, size)
+	if resp == nil {
+		uploadData.deleteKeyfile(cData.Quiet)
+
+		err = execUploader.err
+		if err == nil {
+			err = fmt.Errorf("uploading %s failed", uploadData.Name)
+		}
+
+		return nil, nil, err
+	}
+
+	return resp, execUploader, nil
+}
+
+// Read the manifest of a split file
+func (cData *CommandData) readSplitManifest(resp *libdm.FileDownloadResponse) (*splitManifest, error) {
+	var buff bytes.Buffer
+	digest, extracted, err := saveResponse(resp, &buff, cData.limitDownload, true, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if !resp.VerifyChecksum() {
+		return nil, libdm.ErrChecksumNotMatch
+	}
+
+	if err = cData.verifyDigest(resp, digest, extracted); err != nil {
+		return nil, err
+	}
+
+	var manifest splitManifest
+	if err = json.Unmarshal(buff.Bytes(), &manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, manifest.validate()
+}
+
+// Download all parts of the split file described
+// by the manifest in resp into a single file
+func (cData *CommandData) downloadSplitFile(downloadData *DownloadData, resp *libdm.FileDownloadResponse) error {
+	if len(downloadData.Unpack) > 0 {
+		fmt.Println("Split files can't be unpacked")
+		return ErrInvalidManifest
+	}
+
+	manifest, err := cData.readSplitManifest(resp)
+	if err != nil {
+		printError("reading split manifest", err.Error())
+		return err
+	}
+
+	// Name the file like the original one
+	outFile := resolveOutputFile(path.Base(manifest.Name), strings.TrimSuffix(downloadData.LocalPath, SplitManifestSuffix), false)
+	if strings.HasPrefix(outFile, "/dev/") {
+		fmt.Println("Split files can only be downloaded into regular files")
+		return ErrInvalidManifest
+	}
+
+	if gaw.FileExists(outFile) && !cData.Force {
+		fmt.Printf("File '%s' already exists. Use -f to overwrite it or choose a different outputfile\n", outFile)
+		return os.ErrExist
+	}
+
+	f, err := os.OpenFile(outFile, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0600)
+	if err != nil {
+		printError("creating file", err.Error())
+		return err
+	}
+	defer f.Close()
+
+	var bar *Bar
+	if !cData.Quiet {
+		if downloadData.ProgressView == nil {
+			downloadData.ProgressView = NewProgressView()
+		}
+
+		bar = NewBar(DownloadTask, manifest.Size, manifest.Name, false, len(manifest.Name))
+		downloadData.ProgressView.AddBar(bar)
+	}
+
+	c := make(chan string, 1)
+	go func() {
+		err := cData.downloadSplitParts(manifest, f, downloadData.Parallelism, bar)
+		if err == nil {
+			err = manifest.verify(f)
+		}
+
+		if err != nil {
+			c <- err.Error()
+			return
+		}
+
+		c <- ""
+	}()
+
+	var success bool
+	awaitOrInterrupt(c, func(s os.Signal) {
+		bar.stop("Cancelled. Erasing file!")
+		f.Close()
+		ShredderFile(outFile, -1)
+		os.Exit(1)
+	}, func(s string) {
+		text := fmt.Sprintf("saved '%s'", outFile)
+		if len(s) > 0 {
+			text = getError("downloading file", s)
+			err = errors.New(s)
+		} else {
+			success = true
+		}
+
+		if bar != nil {
+			bar.stop(text)
+		} else {
+			fmt.Println(text)
+		}
+	})
+
+	if downloadData.ProgressView != nil {
+		for i := range downloadData.ProgressView.RawBars {
+			for !downloadData.ProgressView.RawBars[i].done {
+				time.Sleep(50 * time.Millisecond)
+			}
+		}
+	}
+
+	if !success {
+		f.Close()
+		ShredderFile(outFile, -1)
+		return err
+	}
+
+	cData.restoreFileInfo(outFile, resp)
+	cData.runPostDownloadHooks(outFile, downloadData.namespace(cData), resp)
+	return nil
+}
+
+// Download the parts of manifest into f using threads parallel downloads
+func (cData *CommandData) downloadSplitParts(manifest *splitManifest, f io.WriterAt, threads int, bar *Bar) error {
+	if threads < 1 {
+		threads = 1
+	}
+
+	if threads > cData.LibDM.MaxConnectionsPerHost {
+		cData.LibDM.MaxConnectionsPerHost = threads
+	}
+
+	var mx sync.Mutex
+	var firstErr error
+
+	gopool.New(len(manifest.Parts), threads, func(wg *sync.WaitGroup, pos, total, workerID int) interface{} {
+		// Skip remaining parts after a failure
+		mx.Lock()
+		failed := firstErr != nil
+		mx.Unlock()
+		if failed {
+			return nil
+		}
+
+		if err := cData.downloadSplitPart(manifest.Parts[pos], f, manifest.Compressed, bar); err != nil {
+			mx.Lock()
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %s", manifest.Parts[pos].Name, err)
+			}
+			mx.Unlock()
+		}
+
+		return nil
+	}).Run().Wait()
+
+	return firstErr
+}
+
+// Download a single part into its position in f
+func (cData *CommandData) downloadSplitPart(part splitPart, f io.WriterAt, compressed bool, bar *Bar) error {
+	w := &partWriter{w: f, offset: part.Offset, size: part.Size, bar: bar}
+
+	var retried bool
+	return cData.retry(func() error {
+		if retried {
+			w.reset()
+		}
+		retried = true
+
+		resp, err := (&DownloadData{FileID: part.FileID}).doRequest(cData, false)
+		if err != nil {
+			return err
+		}
+
+		digest, extracted, err := saveResponse(resp, w, cData.limitDownload, compressed, nil)
+		if err != nil {
+			return err
+		}
+
+		if w.written != part.Size {
+			return ErrInvalidManifest
+		}
+
+		if !resp.VerifyChecksum() {
+			return libdm.ErrChecksumNotMatch
+		}
+
+		return cData.verifyDigest(resp, digest, extracted)
+	}, cData.printRetry("downloading "+part.Name))
+}
+
+// Verify the SHA-256 digest of the joined file
+func (manifest *splitManifest) verify(f io.ReaderAt) error {
+	digest := sha256.New()
+	if _, err := io.Copy(digest, io.NewSectionReader(f, 0, manifest.Size)); err != nil {
+		return err
+	}
+
+	if hex.EncodeToString(digest.Sum(nil)) != manifest.SHA256 {
+		return ErrDigestNotMatch
+	}
+
+	return nil
+}
+
+// partWriter writes a part at its offset, refusing to exceed its size
+type partWriter struct {
+	w       io.WriterAt
+	offset  int64
+	size    int64
+	written int64
+	bar     *Bar
+}
+
+func (pw *partWriter) Write(b []byte) (int, error) {
+	if pw.written+int64(len(b)) > pw.size {
+		return 0, ErrInvalidManifest
+	}
+
+	n, err := pw.w.WriteAt(b, pw.offset+pw.written)
+	pw.written += int64(n)
+
+	if pw.bar != nil {
+		pw.bar.bar.IncrBy(n)
+	}
+
+	return n, err
+}
+
+// Start writing the part again
+func (pw *partWriter) reset() {
+	if pw.bar != nil {
+		pw.bar.bar.IncrInt64(-pw.written)
+	}
+
+	pw.written = 0
+}
+
+// Replace the parts of split files by their manifest
+// showing the size of all parts together
+func collapseSplitFiles(files []libdm.FileResponseItem) []libdm.FileResponseItem {
+	// Groups are unique per namespace
+	key := func(file *libdm.FileResponseItem) string {
+		if group := splitGroup(file); len(group) > 0 {
+			return file.Attributes.Namespace + "/" + group
+		}
+		return ""
+	}
+
+	sizes := make(map[string]int64)
+	manifests := make(map[string]bool)
+	for i := range files {
+		k := key(&files[i])
+		if len(k) == 0 {
+			continue
+		}
+
+		if isSplitManifest(files[i].Name) {
+			manifests[k] = true
+		} else {
+			sizes[k] += files[i].Size
+		}
+	}
+
+	if len(manifests) == 0 {
+		return files
+	}
+
+	// Parts without their manifest (eg. listed by name
+	// or left over by a failed upload) stay visible
+	collapsed := make([]libdm.FileResponseItem, 0, len(files))
+	for _, file := range files {
+		k := key(&file)
+		if len(k) == 0 || !manifests[k] {
+			collapsed = append(collapsed, file)
+			continue
+		}
+
+		if !isSplitManifest(file.Name) {
+			continue
+		}
+
+		if size, ok := sizes[k]; ok {
+			file.Size = size
+		}
+
+		collapsed = append(collapsed, file)
+	}
+
+	return collapsed
+}
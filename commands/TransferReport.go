@@ -0,0 +1,203 @@
+package commands
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sbani/go-humanizer/units"
+	clitable "gopkg.in/benweidig/cli-table.v2"
+)
+
+// Transfer states
+const (
+	transferSucceeded = "succeeded"
+	transferFailed    = "failed"
+	transferSkipped   = "skipped"
+)
+
+// transferResult the result of a single transfer
+type transferResult struct {
+	Name   string `json:"name"`
+	ID     uint   `json:"id,omitempty"`
+	Status string `json:"status"`
+	Size   int64  `json:"size"`
+	Error  string `json:"error,omitempty"`
+}
+
+// transferReport collects the results of a batch transfer
+type transferReport struct {
+	mx       sync.Mutex
+	start    time.Time
+	results  []transferResult
+	failFast bool
+}
+
+// transferSummary summary of a transfer report
+type transferSummary struct {
+	Succeeded  int              `json:"succeeded"`
+	Failed     int              `json:"failed"`
+	Skipped    int              `json:"skipped"`
+	Bytes      int64            `json:"bytes"`
+	Duration   float64          `json:"duration"`
+	Throughput float64          `json:"throughput"`
+	Results    []transferResult `json:"results"`
+}
+
+// Create a new report. If failFast is true, all
+// transfers after the first failure are skipped
+func newTransferReport(failFast bool) *transferReport {
+	return &transferReport{
+		start:    time.Now(),
+		failFast: failFast,
+	}
+}
+
+func (report *transferReport) add(result transferResult) {
+	if report == nil {
+		return
+	}
+
+	report.mx.Lock()
+	report.results = append(report.results, result)
+	report.mx.Unlock()
+}
+
+// Add a successful transfer
+func (report *transferReport) succeeded(name string, id uint, size int64) {
+	report.add(transferResult{
+		Name:   name,
+		ID:     id,
+		Status: transferSucceeded,
+		Size:   size,
+	})
+}
+
+// Add a failed transfer
+func (report *transferReport) failed(name string, id uint, err error) {
+	result := transferResult{
+		Name:   name,
+		ID:     id,
+		Status: transferFailed,
+	}
+
+	if err != nil {
+		result.Error = responseErrorCause(err)
+	}
+
+	report.add(result)
+}
+
+// Add a skipped transfer
+func (report *transferReport) skipped(name string, id uint, reason string) {
+	report.add(transferResult{
+		Name:   name,
+		ID:     id,
+		Status: transferSkipped,
+		Error:  reason,
+	})
+}
+
+// Return true if a transfer failed
+func (report *transferReport) hasFailed() bool {
+	if report == nil {
+		return false
+	}
+
+	report.mx.Lock()
+	defer report.mx.Unlock()
+
+	for i := range report.results {
+		if report.results[i].Status == transferFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return true if remaining transfers should be skipped
+func (report *transferReport) shouldSkip() bool {
+	return report != nil && report.failFast && report.hasFailed()
+}
+
+// Build the summary of all transfers
+func (report *transferReport) summary() transferSummary {
+	report.mx.Lock()
+	defer report.mx.Unlock()
+
+	summary := transferSummary{
+		Duration: time.Since(report.start).Seconds(),
+		Results:  report.results,
+	}
+
+	for _, result := range report.results {
+		switch result.Status {
+		case transferSucceeded:
+			summary.Succeeded++
+			summary.Bytes += result.Size
+		case transferFailed:
+			summary.Failed++
+		case transferSkipped:
+			summary.Skipped++
+		}
+	}
+
+	if summary.Duration > 0 {
+		summary.Throughput = float64(summary.Bytes) / summary.Duration
+	}
+
+	return summary
+}
+
+// Print the report
+func (cData *CommandData) printTransferReport(report *transferReport) {
+	summary := report.summary()
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(summary))
+		return
+	}
+
+	// Only list all files if something went wrong
+	if summary.Failed > 0 || summary.Skipped > 0 {
+		headingColor := color.New(color.FgHiGreen, color.Underline, color.Bold)
+
+		table := clitable.New()
+		table.ColSeparator = " "
+		table.Padding = 4
+
+		table.AddRow([]interface{}{
+			headingColor.Sprint("Name"), headingColor.Sprint("Status"), headingColor.Sprint("Size"), headingColor.Sprint("Error"),
+		}...)
+
+		for _, result := range summary.Results {
+			status := result.Status
+			switch status {
+			case transferFailed:
+				status = color.HiRedString(status)
+			case transferSkipped:
+				status = color.YellowString(status)
+			}
+
+			var size string
+			if result.Size > 0 {
+				size = units.BinarySuffix(float64(result.Size))
+			}
+
+			table.AddRow([]interface{}{result.Name, status, size, result.Error}...)
+		}
+
+		fmt.Println(table)
+	}
+
+	fmt.Printf("%s %d, %s %d, %s %d. Transferred %s in %s (%s/s)\n",
+		color.HiGreenString("Succeeded:"), summary.Succeeded,
+		color.HiRedString("Failed:"), summary.Failed,
+		color.YellowString("Skipped:"), summary.Skipped,
+		units.BinarySuffix(float64(summary.Bytes)),
+		time.Duration(summary.Duration*float64(time.Second)).Round(time.Millisecond),
+		units.BinarySuffix(summary.Throughput),
+	)
+}
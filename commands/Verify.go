@@ -0,0 +1,263 @@
+package commands
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/JojiiOfficial/gopool"
+	"github.com/fatih/color"
+	clitable "gopkg.in/benweidig/cli-table.v2"
+)
+
+// Verification states
+const (
+	verifyOK            = "ok"
+	verifyCorrupt       = "corrupt"
+	verifyUndecryptable = "undecryptable"
+	verifyMissingKey    = "missing key"
+	verifyUnverified    = "unverified"
+	verifyFailed        = "failed"
+)
+
+// verifyResult the result of verifying a file
+type verifyResult struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Namespace string `json:"ns,omitempty"`
+	Status    string `json:"status"`
+	Checksum  bool   `json:"checksum"`
+	Indexed   bool   `json:"indexed"`
+	Digest    bool   `json:"digest"`
+	SHA256    string `json:"sha256,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Verify downloads a file without storing it and compares it with the
+// checksum of the server and the locally indexed digest. If no file is
+// given, all files in the namespace (or all namespaces) are verified
+func (cData *CommandData) Verify(name string, id uint, threads int) {
+	name, id = GetFileCommandData(name, id)
+
+	if len(name) == 0 && id == 0 {
+		cData.verifyFiles(threads)
+		return
+	}
+
+	result := cData.verifyFile(name, id, "")
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(result))
+	} else if result.Status == verifyFailed {
+		printError("verifying file", result.Error)
+	} else {
+		fmt.Printf("CRC32:\t%s\n", validText(result.Checksum))
+
+		switch {
+		case result.Status == verifyMissingKey:
+			fmt.Printf("SHA256:\t%s\n", color.YellowString("can't be compared (missing key)"))
+		case result.Status == verifyUnverified:
+			fmt.Printf("SHA256:\t%s\n", color.YellowString("not indexed, the decryption can't be confirmed"))
+		case result.Indexed:
+			fmt.Printf("SHA256:\t%s\n", validText(result.Digest))
+		default:
+			fmt.Printf("SHA256:\t%s\n", color.YellowString("not indexed"))
+		}
+
+		if len(result.Error) > 0 {
+			printError("decrypting file", result.Error)
+		}
+	}
+
+	if result.Status != verifyOK {
+		os.Exit(1)
+	}
+}
+
+// Verify all files in the namespace or all namespaces
+func (cData *CommandData) verifyFiles(threads int) {
+	allNamespaces := cData.All || cData.AllNamespaces
+
+	resp, err := cData.listFiles("", 0, allNamespaces, cData.FileAttributes, 2)
+	if err != nil {
+		printResponseError(err, "listing files")
+		os.Exit(1)
+	}
+
+	if len(resp.Files) == 0 {
+		fmt.Println("No files found")
+		return
+	}
+
+	// Open the keystore before using it in parallel
+	cData.HasKeystoreSupport()
+	cData.LibDM.MaxConnectionsPerHost = threads
+
+	results := make([]verifyResult, len(resp.Files))
+	var mx sync.Mutex
+
+	gopool.New(len(resp.Files), threads, func(wg *sync.WaitGroup, pos, total, workerID int) interface{} {
+		file := resp.Files[pos]
+
+		result := cData.verifyFile("", file.ID, file.Attributes.Namespace)
+		result.Name = file.Name
+		result.Namespace = file.Attributes.Namespace
+		results[pos] = *result
+
+		if !cData.Quiet && !cData.OutputJSON {
+			mx.Lock()
+			fmt.Printf("%s %s\n", verifyStatusText(result.Status), file.Name)
+			mx.Unlock()
+		}
+
+		return nil
+	}).Run().Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Namespace < results[j].Namespace
+	})
+
+	if !cData.printVerifyReport(results) {
+		os.Exit(1)
+	}
+}
+
+// Download a file through the decrypt and extract pipeline into
+// a digest and check the checksum and the indexed digest
+func (cData *CommandData) verifyFile(name string, id uint, namespace string) *verifyResult {
+	result := &verifyResult{
+		ID:     id,
+		Name:   name,
+		Status: verifyFailed,
+	}
+
+	resp, err := (&DownloadData{FileName: name, FileID: id, Namespace: namespace}).doRequest(cData, false)
+	if err != nil {
+		result.Error = responseErrorCause(err)
+		return result
+	}
+
+	result.ID = resp.FileID
+	result.Name = resp.ServerFileName
+
+	entry, err := cData.index.get(resp.FileID)
+	if err != nil {
+		printWarning("reading index", err.Error())
+	}
+
+	// Indexed digests are built from the uncompressed content
+	extract := entry != nil && entry.Compressed
+
+	encrypted := len(resp.Encryption) > 0
+	missingKey := encrypted && !resp.DownloadRequest.Decrypt
+
+	digest, extracted, err := saveResponse(resp, ioutil.Discard, cData.limitDownload, extract, nil)
+	if err != nil {
+		result.Error = err.Error()
+		if encrypted {
+			result.Status = verifyUndecryptable
+		}
+		return result
+	}
+
+	result.Checksum = resp.VerifyChecksum()
+	result.SHA256 = hex.EncodeToString(digest.Sum(nil))
+
+	if indexed := cData.indexedDigest(resp, extracted); len(indexed) > 0 {
+		result.Indexed = true
+		result.Digest = indexed == result.SHA256
+	}
+
+	switch {
+	case !result.Checksum:
+		result.Status = verifyCorrupt
+	case missingKey:
+		result.Status = verifyMissingKey
+	case result.Indexed && !result.Digest && encrypted:
+		// The stored data is fine, so the key must be wrong
+		result.Status = verifyUndecryptable
+	case result.Indexed && !result.Digest:
+		result.Status = verifyCorrupt
+	case !result.Indexed && encrypted:
+		// Decrypting with a wrong key doesn't fail
+		result.Status = verifyUnverified
+	default:
+		result.Status = verifyOK
+	}
+
+	return result
+}
+
+// Print the results of verifying multiple files.
+// Returns false if a file isn't valid
+func (cData *CommandData) printVerifyReport(results []verifyResult) bool {
+	counts := make(map[string]int)
+	for i := range results {
+		counts[results[i].Status]++
+	}
+
+	if cData.OutputJSON {
+		fmt.Println(toJSON(map[string]interface{}{
+			"counts":  counts,
+			"results": results,
+		}))
+		return counts[verifyOK] == len(results)
+	}
+
+	// List all invalid files
+	if counts[verifyOK] < len(results) {
+		headingColor := color.New(color.FgHiGreen, color.Underline, color.Bold)
+
+		table := clitable.New()
+		table.ColSeparator = " "
+		table.Padding = 4
+
+		table.AddRow([]interface{}{
+			headingColor.Sprint("ID"), headingColor.Sprint("Name"), headingColor.Sprint("Namespace"), headingColor.Sprint("Status"), headingColor.Sprint("Error"),
+		}...)
+
+		for _, result := range results {
+			if result.Status == verifyOK {
+				continue
+			}
+
+			table.AddRow([]interface{}{result.ID, result.Name, result.Namespace, verifyStatusText(result.Status), result.Error}...)
+		}
+
+		fmt.Println()
+		fmt.Println(table)
+	}
+
+	fmt.Printf("Verified %d files: %s %d, %s %d, %s %d, %s %d, %s %d, %s %d\n", len(results),
+		color.HiGreenString("ok:"), counts[verifyOK],
+		color.HiRedString("corrupt:"), counts[verifyCorrupt],
+		color.HiRedString("undecryptable:"), counts[verifyUndecryptable],
+		color.YellowString("missing key:"), counts[verifyMissingKey],
+		color.YellowString("unverified:"), counts[verifyUnverified],
+		color.YellowString("failed:"), counts[verifyFailed],
+	)
+
+	return counts[verifyOK] == len(results)
+}
+
+func verifyStatusText(status string) string {
+	switch status {
+	case verifyOK:
+		return color.HiGreenString(status)
+	case verifyCorrupt, verifyUndecryptable:
+		return color.HiRedString(status)
+	}
+
+	return color.YellowString(status)
+}
+
+func validText(valid bool) string {
+	if valid {
+		return color.HiGreenString("valid")
+	}
+
+	return color.HiRedString("invalid")
+}
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"strconv"
 	"strings"
@@ -16,9 +17,10 @@ import (
 
 // CommandData data for commands
 type CommandData struct {
-	LibDM   *libdm.LibDM
-	Command string
-	Config  *dmConfig.Config
+	LibDM     *libdm.LibDM
+	Command   string
+	Config    *dmConfig.Config
+	CLIConfig *CLIConfig
 
 	// Encryption
 	keystore            *libdm.Keystore
@@ -31,13 +33,38 @@ type CommandData struct {
 	FileAttributes          libdm.FileAttributes
 	Details                 uint8
 	NameLen                 int
-	All                     bool
+	All, AllNamespaces      bool
 	NoRedaction, OutputJSON bool
 	Yes, Force, Quiet       bool
 	NoDecrypt, NoEmojis     bool
 	VerifyFile              bool
-	Compression             bool
+	Compression             string
+	CompressionLevel        int
 	Extract                 bool
+	NoPreserve              bool
+	NoHooks                 bool
+	FailFast                bool
+
+	// Bandwidth limits
+	rateLimiter, uploadLimiter, downloadLimiter *rateLimiter
+
+	retryPolicy *RetryPolicy
+
+	fileFilter *fileFilter
+
+	index *fileIndex
+
+	// Writer for messages, stdout if nil
+	messages io.Writer
+}
+
+// Returns the writer to print messages to
+func (cData *CommandData) messageWriter() io.Writer {
+	if cData.messages != nil {
+		return cData.messages
+	}
+
+	return os.Stdout
 }
 
 // Init init CommandData
@@ -59,6 +86,10 @@ func (cData *CommandData) Init() bool {
 	// Create new dmanager lib object
 	cData.LibDM = libdm.NewLibDM(config)
 
+	if cData.Config != nil {
+		cData.index = newFileIndex(cData.Config.File)
+	}
+
 	// return success
 	return true
 }
@@ -111,6 +142,11 @@ func (cData *CommandData) CloseKeystore() {
 	cData.keystore.Close()
 }
 
+// CloseIndex closes the local index
+func (cData *CommandData) CloseIndex() {
+	cData.index.close()
+}
+
 // HasKeystoreSupport return true if kesytore is set up
 // correctly and is enabled
 func (cData *CommandData) HasKeystoreSupport() bool {
@@ -119,8 +155,9 @@ func (cData *CommandData) HasKeystoreSupport() bool {
 }
 
 // Print nice output for a file upload
-// If total files is > 1 only a summary is shown
-func (cData CommandData) printUploadResponse(ur *libdm.UploadResponse, uploadData *UploadData, short bool, bar *Bar) string {
+// If total files is > 1 only a summary is shown.
+// A rendered QR code gets appended to the output
+func (cData CommandData) printUploadResponse(ur *libdm.UploadResponse, uploadData *UploadData, short bool, bar *Bar, qr string) string {
 	sID := strconv.FormatUint(uint64(ur.FileID), 10)
 	sName := ur.Filename
 	sNamespace := ur.Namespace
@@ -146,6 +183,10 @@ func (cData CommandData) printUploadResponse(ur *libdm.UploadResponse, uploadDat
 			text = fmt.Sprintf("%s %s; %s %s", color.HiGreenString("ID"), sID, color.HiGreenString("Name:"), sName)
 		}
 
+		if len(qr) > 0 {
+			text += "\n" + qr
+		}
+
 		if bar != nil {
 			bar.doneTextChan <- text
 		}
@@ -172,6 +213,9 @@ func (cData CommandData) printUploadResponse(ur *libdm.UploadResponse, uploadDat
 
 	// Render table
 	ts := table.String()
+	if len(qr) > 0 {
+		ts += "\n" + qr
+	}
 
 	if bar != nil {
 		bar.doneTextChan <- ts
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
@@ -37,11 +38,19 @@ func getError(message interface{}, err string) string {
 }
 
 func printError(message interface{}, err string) {
-	fmt.Println(getError(message, err))
+	fprintError(os.Stdout, message, err)
+}
+
+func fprintError(w io.Writer, message interface{}, err string) {
+	fmt.Fprintln(w, getError(message, err))
 }
 
 func printWarning(message interface{}, err string) {
-	fmt.Printf("%s %s: %s\n", color.YellowString("Warn"), message, err)
+	fprintWarning(os.Stdout, message, err)
+}
+
+func fprintWarning(w io.Writer, message interface{}, err string) {
+	fmt.Fprintf(w, "%s %s: %s\n", color.YellowString("Warn"), message, err)
 }
 
 func printJSONError(message interface{}) {
@@ -285,27 +294,28 @@ func printResponseError(err error, msg string) {
 		return
 	}
 
+	printError(msg, responseErrorCause(err))
+}
+
+// Returns the message describing err
+func responseErrorCause(err error) string {
 	switch err.(type) {
 	case *libdm.ResponseErr:
 		lrerr := err.(*libdm.ResponseErr)
 
-		var cause string
-
 		if lrerr.Response != nil {
-			cause = lrerr.Response.Message
+			return lrerr.Response.Message
 		} else if lrerr.Err != nil {
-			cause = lrerr.Err.Error()
-		} else {
-			cause = lrerr.Error()
+			return lrerr.Err.Error()
 		}
 
-		printError(msg, cause)
+		return lrerr.Error()
 	default:
 		if err != nil {
-			printError(msg, err.Error())
-		} else {
-			printError(msg, "no error provided")
+			return err.Error()
 		}
+
+		return "no error provided"
 	}
 }
 
@@ -487,3 +497,36 @@ func IsPiped() bool {
 	fi, err := os.Stdout.Stat()
 	return err == nil && (fi.Mode()&os.ModeCharDevice) == 0
 }
+
+// globMatch matches a slash separated path against a glob
+// pattern. In addition to the path.Match syntax a "**"
+// segment matches any amount of directories
+func globMatch(pattern, name string) bool {
+	return globMatchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func globMatchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest at every depth
+			for i := 0; i <= len(name); i++ {
+				if globMatchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
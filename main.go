@@ -51,12 +51,23 @@ var (
 	appGroups             = app.Flag("group", "Specify groups to use").Short('g').Strings()
 	appNamespace          = app.Flag("namespace", "Specify the namespace to use").Default("default").Short('n').HintAction(hintListNamespaces).String()
 	appAll                = app.Flag("all", "Do action for all found files").Short('a').Bool()
+	appAllNamespaces      = app.Flag("all-namespaces", "Apply tag/group commands to all namespaces").Bool()
 	appVerify             = app.Flag("verify", "Verify a file using a checksum to prevent errors").Bool()
 	appNoDecrypt          = app.Flag("no-decrypt", "Don't decrypt files").Bool()
 	appForce              = app.Flag("force", "Forces an action").Short('f').Bool()
 	appFileEncryption     = app.Flag("encryption", "Encrypt/Decrypt the file").Short('e').HintOptions([]string{"age", "aes"}...).String()
-	appDisableCompression = appUpload.Flag("compressed", "Compress files while uploading").Bool()
-	appDecompress         = fileDownloadCmd.Flag("extract", "Extract a gzipped file while downloading").Bool()
+	appDisableCompression = appUpload.Flag("compressed", "Compress files using gzip while uploading").Bool()
+	appCompression        = appUpload.Flag("compression", "Compress files while uploading using the given algorithm").HintOptions(commands.CompressionAlgorithms...).Enum(commands.CompressionAlgorithms...)
+	appCompressionLevel   = appUpload.Flag("compression-level", "The compression level. Defaults to the default level of the algorithm. For xz it only selects the dictionary size of the preset").Int()
+	appDecompress         = fileDownloadCmd.Flag("extract", "Decompress a gzip, zstd or xz compressed file while downloading").Bool()
+	appNoHooks            = app.Flag("no-hooks", "Don't run hooks defined in the cli config").Bool()
+	appFailFast           = app.Flag("fail-fast", "Stop batch transfers after the first failure").Bool()
+
+	// Bandwidth related flags
+	appLimitRate     = app.Flag("limit-rate", "Limit the total bandwidth of all transfers (eg. 5M)").String()
+	appLimitUpload   = app.Flag("limit-upload", "Limit the bandwidth of uploads").String()
+	appLimitDownload = app.Flag("limit-download", "Limit the bandwidth of downloads").String()
+	appRetries       = app.Flag("retries", "Max retries of failed requests. Overwrites the cli config").Default("-1").Int()
 
 	// Output related flags
 	appDetails     = app.Flag("details", "Print more details of something").Short('d').Counter()
@@ -126,7 +137,14 @@ var (
 	fileUploadReplaceSameName = appUpload.Flag("replace-same-name", "Replace file with same name in selected namespace").Bool()
 	fileUploadDeletInvaid     = app.Flag("delete-invaid", "Deletes a file if it's checksum is invalid").Bool()
 	fileUploadSetClipboard    = app.Flag("set-clip", "Set clipboard to pubilc url").Bool()
+	fileUploadQR              = app.Flag("qr", "Show the public url as QR code").Bool()
+	fileUploadQRFile          = app.Flag("qr-file", "Write the public url as QR code (PNG) into the given file").String()
 	fileUploadNoArchiving     = app.Flag("no-archive", "Don't archive folder, upload all files in a given folder separately").Bool()
+	fileUploadExclude         = appUpload.Flag("exclude", "Ignore files of uploaded folders matching the pattern (gitignore syntax)").Strings()
+	fileUploadInclude         = appUpload.Flag("include", "Only upload files of folders matching the pattern (gitignore syntax)").Strings()
+	fileUploadKeepPaths       = appUpload.Flag("keep-paths", "Name files of folders uploaded using --no-archive by their relative path").Bool()
+	fileUploadArchiveFormat   = appUpload.Flag("archive-format", "The format to archive folders in").Default(commands.ArchiveTar).HintOptions(commands.ArchiveFormats...).Enum(commands.ArchiveFormats...)
+	fileUploadSplit           = appUpload.Flag("split", "Upload files bigger than the given size (eg. 2G) in parts").String()
 
 	// -- List
 	appFileCmd           = app.Command("file", "Do something with a file").Alias("f")
@@ -178,38 +196,77 @@ var (
 	fileMoveCmd   = app.Command("mv", "Move a file into a new namespace")
 	fileMoveFile  = fileMoveCmd.Arg("file", "The file to move").Required().String()
 	fileMoveNewNs = fileMoveCmd.Arg("newNamespace", "The namespace to move the given file to").Required().HintAction(hintListNamespaces).String()
+	// -- Copy
+	fileCopyCmd   = app.Command("cp", "Copy a file into a namespace")
+	fileCopyFile  = fileCopyCmd.Arg("file", "The file to copy").String()
+	fileCopyNewNs = fileCopyCmd.Arg("newNamespace", "The namespace to copy the given file to").HintAction(hintListNamespaces).String()
 	// -- Download
-	fileDownloadCmd     = app.Command("download", "Download a file from the server").Alias("dl")
-	fileDownloadName    = fileDownloadCmd.Arg("fileName", "Download files with this name").String()
-	fileDownloadID      = fileDownloadCmd.Arg("fileId", "Specify the fileID").Uint()
-	fileDownloadPath    = fileDownloadCmd.Flag("output", "Where to store the file").Default("./").Short('o').String()
-	fileDownloadPreview = fileDownloadCmd.Flag("preview", "Whether you want to open the file after downloading it").Bool()
+	fileDownloadCmd        = app.Command("download", "Download a file from the server").Alias("dl")
+	fileDownloadName       = fileDownloadCmd.Arg("fileName", "Download files with this name").String()
+	fileDownloadID         = fileDownloadCmd.Arg("fileId", "Specify the fileID").Uint()
+	fileDownloadPath       = fileDownloadCmd.Flag("output", "Where to store the file").Default("./").Short('o').String()
+	fileDownloadPreview    = fileDownloadCmd.Flag("preview", "Whether you want to open the file after downloading it").Bool()
+	fileDownloadNoPreserve = fileDownloadCmd.Flag("no-preserve", "Don't restore the mode and modification time of the uploaded file").Bool()
+	fileDownloadUnpack     = fileDownloadCmd.Flag("unpack", "Extract a tar, tar.gz or zip archive into the given directory").String()
+	fileDownloadStrip      = fileDownloadCmd.Flag("strip-components", "Strip n leading path components of archive members while unpacking").Int()
+	fileDownloadTar        = fileDownloadCmd.Flag("tar", "Write all matching files as a single tar stream into the given file ('-' for stdout)").String()
 	// -- Publish
 	filePublishCmd    = app.Command("publish", "publish a file").Alias("pub").Alias("p")
 	filePublishName   = filePublishCmd.Arg("fileName", "Name of the file that should be published").Required().String()
 	filePublishID     = filePublishCmd.Arg("fileID", "FileID of specified file. Only required if mulitple files with same name are available").Uint()
 	publishPublicName = filePublishCmd.Flag("public-name", "Specify the public filename").String()
 	// -- UnPublish
-	fileUnPublishCmd  = app.Command("unpublish", "unpublish a file").Alias("unpub")
-	fileUnPublishName = fileUnPublishCmd.Arg("fileName", "Name of the file that should be unpublished").Required().String()
-	fileUnPublishID   = fileUnPublishCmd.Arg("fileID", "FileID of specified file. Only required if mulitple files with same name are available").Uint()
+	fileUnPublishCmd   = app.Command("unpublish", "unpublish a file").Alias("unpub")
+	fileUnPublishName  = fileUnPublishCmd.Arg("fileName", "Name of the file that should be unpublished. Optional using --all with a filter").String()
+	fileUnPublishID    = fileUnPublishCmd.Arg("fileID", "FileID of specified file. Only required if mulitple files with same name are available").Uint()
+	unpublishOlderThan = fileUnPublishCmd.Flag("older-than", "Unpublish public files of all namespaces older than n days (requires --all)").Int()
+	unpublishTags      = fileUnPublishCmd.Flag("sensitive-tag", "Unpublish public files of all namespaces having one of the tags (requires --all)").Strings()
 	// -- View
 	viewCmd       = appFileCmd.Command("view", "View something").Alias("v")
 	viewFileName  = viewCmd.Arg("fileName", "filename of file to view").Required().String()
 	viewFileID    = viewCmd.Arg("fileID", "fileID of file to view").Uint()
 	viewNoPreview = viewCmd.Flag("no-preview", "Disable preview for command").Bool()
 	viewPreview   = viewCmd.Flag("preview", "Show preview for command").Bool()
+	// -- Verify
+	verifyCmd      = app.Command("verify", "Verify the integrity of a file or all files in a namespace (--all for all namespaces)")
+	verifyFileName = verifyCmd.Arg("fileName", "Name of the file to verify").String()
+	verifyFileID   = verifyCmd.Arg("fileID", "FileID of the file to verify").Uint()
 	// -- Cat
 	catCmd      = app.Command("cat", "View something").Alias("v")
 	catFileName = catCmd.Arg("fileName", "filename of file to view").Required().String()
 	catFileID   = catCmd.Arg("fileID", "fileID of file to view").Uint()
 
+	//
+	// ---------> Public commands --------------------------------------
+	publicCmd = app.Command("public", "Inspect the public files of all namespaces")
+	// -- List
+	publicListCmd = publicCmd.Command("list", "List all public files").Alias("ls")
+	// -- Audit
+	publicAuditCmd       = publicCmd.Command("audit", "List public files which are too old or have sensitive tags")
+	publicAuditOlderThan = publicAuditCmd.Flag("older-than", "Flag public files older than n days").Int()
+	publicAuditTags      = publicAuditCmd.Flag("sensitive-tag", "Flag public files having one of the tags").Strings()
+
+	//
+	// ---------> Archive commands --------------------------------------
+	archiveCmd = app.Command("archive", "Inspect stored archives without saving them").Alias("ar")
+	// -- List
+	archiveListCmd      = archiveCmd.Command("list", "List the members of an archive").Alias("ls")
+	archiveListFileName = archiveListCmd.Arg("fileName", "Name of the archive").Required().String()
+	archiveListFileID   = archiveListCmd.Arg("fileID", "FileID of the archive").Uint()
+	// -- Get
+	archiveGetCmd     = archiveCmd.Command("get", "Extract members of an archive")
+	archiveGetFile    = archiveGetCmd.Arg("file", "Name or ID of the archive").Required().String()
+	archiveGetMembers = archiveGetCmd.Arg("member", "Paths, directories or glob patterns of the members to extract").Required().Strings()
+	archiveGetOutput  = archiveGetCmd.Flag("output", "Directory to extract the members into").Short('o').Default("./").String()
+	archiveGetStrip   = archiveGetCmd.Flag("strip-components", "Strip n leading path components of the members").Int()
+
 	//
 	// ---------> Tag commands --------------------------------------
 	tagCmd = app.Command("tag", "Do something with tags").Alias("t")
 
 	// -- List
-	tagListCmd = app.Command("tags", "List existing tags").Alias("ts")
+	tagListCmd    = app.Command("tags", "List existing tags").Alias("ts")
+	tagListCounts = tagListCmd.Flag("counts", "Show the amount and size of files per tag").Bool()
 	// -- Delete
 	tagDeleteCmd  = tagCmd.Command("delete", "Delete a tag").Alias("rm").Alias("del")
 	tagDeleteName = tagDeleteCmd.Arg("tagName", "Name of tag to delete").Required().String()
@@ -217,13 +274,19 @@ var (
 	tagUpdateCmd     = tagCmd.Command("update", "Update a tag").Alias("u")
 	tagUpdateName    = tagUpdateCmd.Arg("tagname", "Name of the tag that should be updated").Required().String()
 	tagUpdateNewName = tagUpdateCmd.Flag("new-name", "New name of a tag").String()
+	tagUpdateRegex   = tagUpdateCmd.Flag("regex", "Rename all tags matching the regex. New name can use $1 etc").Bool()
+	// -- Merge
+	tagMergeCmd  = tagCmd.Command("merge", "Merge a tag into another tag")
+	tagMergeFrom = tagMergeCmd.Arg("tag", "Name of the tag to merge").Required().String()
+	tagMergeInto = tagMergeCmd.Arg("into", "Name of the tag to merge into").Required().String()
 
 	//
 	// ---------> Group commands --------------------------------------
 	groupCmd = app.Command("group", "Do something with groups").Alias("g").Alias("gr")
 
 	// -- List
-	groupListCmd = app.Command("groups", "List existing groups").Alias("gs")
+	groupListCmd    = app.Command("groups", "List existing groups").Alias("gs")
+	groupListCounts = groupListCmd.Flag("counts", "Show the amount and size of files per group").Bool()
 	// -- Delete
 	groupDeleteCmd  = groupCmd.Command("delete", "Delete a group").Alias("rm").Alias("del")
 	groupDeleteName = groupDeleteCmd.Arg("groupName", "Name of group to delete").Required().String()
@@ -231,6 +294,11 @@ var (
 	groupUpdateCmd     = groupCmd.Command("update", "Update a group").Alias("u")
 	groupUpdateName    = groupUpdateCmd.Arg("groupName", "Name of the group that should be updated").Required().String()
 	groupUpdateNewName = groupUpdateCmd.Flag("new-name", "Rename a group").String()
+	groupUpdateRegex   = groupUpdateCmd.Flag("regex", "Rename all groups matching the regex. New name can use $1 etc").Bool()
+	// -- Merge
+	groupMergeCmd  = groupCmd.Command("merge", "Merge a group into another group")
+	groupMergeFrom = groupMergeCmd.Arg("group", "Name of the group to merge").Required().String()
+	groupMergeInto = groupMergeCmd.Arg("into", "Name of the group to merge into").Required().String()
 
 	//
 	// ---------> Namespace commands --------------------------------------
@@ -257,6 +325,21 @@ var (
 	namespaceDownloadExcludeTags   = namespaceDownloadCmd.Flag("exclude-tags", "Exclude files having specified tags(s) from getting downloaded").Strings()
 	namespaceDownloadExcludeFiles  = namespaceDownloadCmd.Flag("exclude-files", "Exclude files by ID").Strings()
 	namespaceDownloadOutputDir     = namespaceDownloadCmd.Flag("output", "Save namespace in a custom directory than the namespacename").Short('o').Default("./").String()
+	namespaceDownloadNoPreserve    = namespaceDownloadCmd.Flag("no-preserve", "Don't restore the modes and modification times of the uploaded files").Bool()
+	namespaceDownloadOrder         = namespaceDownloadCmd.Flag("order", "The order to download the files in").HintOptions(commands.AvailableOrders...).String()
+	// -- Upload
+	namespaceUploadCmd       = namespaceCmd.Command("upload", "Upload all files of a directory into a namespace").Alias("up")
+	namespaceUploadDir       = namespaceUploadCmd.Arg("dir", "The directory to upload").HintAction(hintListFiles).Required().String()
+	namespaceUploadNs        = namespaceUploadCmd.Arg("namespace", "The namespace to upload the files to").HintAction(hintListNamespaces).Required().String()
+	namespaceUploadMapGroups = namespaceUploadCmd.Flag("map-group", "Assign files matching a pattern to a group (<pattern>=<group>)").Strings()
+	namespaceUploadMapTags   = namespaceUploadCmd.Flag("map-tag", "Assign a tag to files matching a pattern (<pattern>=<tag>)").Strings()
+	namespaceUploadKeepPaths = namespaceUploadCmd.Flag("keep-paths", "Name files by their path relative to dir").Bool()
+	namespaceUploadExclude   = namespaceUploadCmd.Flag("exclude", "Ignore files matching the pattern (gitignore syntax)").Strings()
+	namespaceUploadInclude   = namespaceUploadCmd.Flag("include", "Only upload files matching the pattern (gitignore syntax)").Strings()
+	// -- Clone
+	namespaceCloneCmd = namespaceCmd.Command("clone", "Copy all files of a namespace into an other namespace")
+	namespaceCloneSrc = namespaceCloneCmd.Arg("source", "The namespace to copy the files from").HintAction(hintListNamespaces).Required().String()
+	namespaceCloneDst = namespaceCloneCmd.Arg("destination", "The namespace to copy the files to").HintAction(hintListNamespaces).Required().String()
 
 	//
 	// ---------> Keystore commands --------------------------------------
@@ -285,6 +368,7 @@ var (
 
 var (
 	config       *dmConfig.Config
+	cliConfig    *commands.CLIConfig
 	appTrimName  int
 	unmodifiedNS string
 )
@@ -296,8 +380,15 @@ func main() {
 	// Init random seed from gaw
 	gaw.Init()
 
+	// Expand aliases defined in the cli config
+	args := expandAliases(os.Args[1:])
+
+	// Add plugins found in PATH as commands
+	registerPlugins()
+	args, pluginArgs := splitPluginArgs(args)
+
 	// Prase cli flags
-	parsed := kingpin.MustParse(app.Parse(os.Args[1:]))
+	parsed := kingpin.MustParse(app.Parse(args))
 
 	// Init config
 	if !initConfig(parsed) {
@@ -321,6 +412,13 @@ func main() {
 		return
 	}
 	defer commandData.CloseKeystore()
+	defer commandData.CloseIndex()
+
+	// Run plugin
+	if executable, ok := plugins[parsed]; ok {
+		runPlugin(executable, pluginArgs, commandData)
+		return
+	}
 
 	// Run desired command
 	runCommand(parsed, commandData)
@@ -340,6 +438,14 @@ func initConfig(parsed string) bool {
 		if parsed != setupCmd.FullCommand() {
 			return false
 		}
+
+		return true
+	}
+
+	// Load cli specific config
+	cliConfig, err = commands.LoadCLIConfig(config.File)
+	if err != nil {
+		log.Fatalln(err)
 	}
 
 	return true
@@ -366,6 +472,9 @@ func initDefaults() {
 		if len(*appFilesOrder) == 0 {
 			*appFilesOrder = config.GetDefaultOrder()
 		}
+		if len(*appFileTreeOrder) == 0 {
+			*appFileTreeOrder = config.GetDefaultOrder()
+		}
 		if !*appVerify && config.User.ForceVerify {
 			*appVerify = true
 		}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/DataManager-Go/DataManagerCLI/commands"
+	"github.com/JojiiOfficial/gaw"
+)
+
+// PluginPrefix prefix of executables used as plugins.
+// 'manager foo' runs 'manager-foo' if found in PATH
+const PluginPrefix = "manager-"
+
+// Plugins found in PATH. Name -> executable
+var plugins map[string]string
+
+// Find all plugins in PATH. Executables in
+// earlier PATH entries take precedence
+func findPlugins() map[string]string {
+	found := make(map[string]string)
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		files, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() || !strings.HasPrefix(name, PluginPrefix) {
+				continue
+			}
+
+			if runtime.GOOS == "windows" {
+				if !strings.HasSuffix(strings.ToLower(name), ".exe") {
+					continue
+				}
+				name = name[:len(name)-4]
+			} else if file.Mode()&0111 == 0 {
+				continue
+			}
+
+			name = strings.TrimPrefix(name, PluginPrefix)
+			if _, ok := found[name]; !ok && len(name) > 0 {
+				found[name] = filepath.Join(dir, file.Name())
+			}
+		}
+	}
+
+	return found
+}
+
+// Register plugins as commands to list them in --help.
+// Builtin commands can't be overwritten by plugins
+func registerPlugins() {
+	plugins = findPlugins()
+
+	for name, executable := range plugins {
+		if isBuiltinCommand(name) {
+			delete(plugins, name)
+			continue
+		}
+
+		app.Command(name, fmt.Sprintf("Plugin (%s)", executable))
+	}
+}
+
+// Split args into the args parsed by the app and the args passed
+// to a plugin. Everything after the plugin name belongs to the plugin
+func splitPluginArgs(args []string) ([]string, []string) {
+	i := commandArgIndex(args)
+	if i < 0 {
+		return args, nil
+	}
+
+	if _, ok := plugins[args[i]]; ok {
+		return args[:i+1], args[i+1:]
+	}
+
+	return args, nil
+}
+
+// Returns the index of the first positional arg
+// which is the command or -1 if there is none
+func commandArgIndex(args []string) int {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+
+		// Skip flags and their values
+		if strings.HasPrefix(arg, "-") {
+			if !strings.Contains(arg, "=") && flagTakesValue(arg) {
+				i++
+			}
+			continue
+		}
+
+		return i
+	}
+
+	return -1
+}
+
+// Return true if name is a builtin command or alias
+func isBuiltinCommand(name string) bool {
+	for _, cmd := range app.Model().Commands {
+		if cmd.Name == name || gaw.IsInStringArray(name, cmd.Aliases) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return true if the flag requires a value
+func flagTakesValue(arg string) bool {
+	for _, flag := range app.Model().Flags {
+		var matches bool
+		if strings.HasPrefix(arg, "--") {
+			matches = flag.Name == arg[2:]
+		} else if len(arg) > 1 {
+			// Use the last flag of combined short flags (-ay)
+			matches = flag.Short == rune(arg[len(arg)-1])
+		}
+
+		if matches {
+			return !flag.IsBoolFlag()
+		}
+	}
+
+	return false
+}
+
+// Run a plugin passing all relevant settings as env vars.
+// Exits with the exitcode of the plugin
+func runPlugin(executable string, args []string, cData *commands.CommandData) {
+	cmd := exec.Command(executable, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), pluginEnv(cData)...)
+
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			os.Exit(exitErr.ExitCode())
+		}
+
+		fmt.Println("Error running plugin:", err)
+		os.Exit(1)
+	}
+}
+
+// Build the env vars passed to plugins
+func pluginEnv(cData *commands.CommandData) []string {
+	boolEnv := func(b bool) string {
+		if b {
+			return "1"
+		}
+		return "0"
+	}
+
+	env := map[string]string{
+		"NAMESPACE":   cData.FileAttributes.Namespace,
+		"TAGS":        strings.Join(cData.FileAttributes.Tags, ","),
+		"GROUPS":      strings.Join(cData.FileAttributes.Groups, ","),
+		"JSON":        boolEnv(cData.OutputJSON),
+		"QUIET":       boolEnv(cData.Quiet),
+		EnVarNoColor:  boolEnv(*appNoColor),
+		EnVarNoEmojis: boolEnv(cData.NoEmojis),
+	}
+
+	if executable, err := os.Executable(); err == nil {
+		env["BIN"] = executable
+	}
+
+	if config != nil {
+		env[EnVarConfigFile] = config.File
+		env["URL"] = config.Server.URL
+		env["USERNAME"] = config.User.Username
+		env["IGNORE_CERT"] = boolEnv(config.Server.IgnoreCert)
+
+		if token, err := config.GetToken(); err == nil {
+			env["TOKEN"] = token
+		}
+	}
+
+	var vars []string
+	for k, v := range env {
+		vars = append(vars, getEnVar(k)+"="+v)
+	}
+
+	return vars
+}